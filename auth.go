@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"slices"
+)
+
+// basicAuth requires HTTP Basic credentials matching user and password,
+// except for requests to the paths in except. Empty credentials disable the
+// check.
+func basicAuth(user, password string, except ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if user == "" && password == "" {
+			return next
+		}
+		wantUser := sha256.Sum256([]byte(user))
+		wantPassword := sha256.Sum256([]byte(password))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(except, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Comparing hashes keeps the comparison constant-time
+			// regardless of the length of the credentials.
+			u, p, ok := r.BasicAuth()
+			gotUser := sha256.Sum256([]byte(u))
+			gotPassword := sha256.Sum256([]byte(p))
+			if !ok ||
+				subtle.ConstantTimeCompare(gotUser[:], wantUser[:])&
+					subtle.ConstantTimeCompare(gotPassword[:], wantPassword[:]) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+				writeError(w, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	protected := basicAuth("admin", "s3cret", "/healthz")(ok)
+	open := basicAuth("", "")(ok)
+
+	for _, tt := range []struct {
+		name           string
+		h              http.Handler
+		path           string
+		user, password string
+		status         int
+	}{
+		{"correct credentials", protected, "/metrics", "admin", "s3cret", http.StatusOK},
+		{"wrong password", protected, "/metrics", "admin", "guess", http.StatusUnauthorized},
+		{"wrong user", protected, "/metrics", "root", "s3cret", http.StatusUnauthorized},
+		{"password prefix", protected, "/metrics", "admin", "s3", http.StatusUnauthorized},
+		{"no credentials", protected, "/metrics", "", "", http.StatusUnauthorized},
+		{"exempt path", protected, "/healthz", "", "", http.StatusOK},
+		{"exempt path prefix only", protected, "/healthz/x", "", "", http.StatusUnauthorized},
+		{"disabled", open, "/metrics", "", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.user != "" || tt.password != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		tt.h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if challenge := rec.Header().Get("WWW-Authenticate"); (challenge != "") != (tt.status == http.StatusUnauthorized) {
+			t.Errorf("%s: WWW-Authenticate %q", tt.name, challenge)
+		}
+	}
+}
+
+func TestRunAdminAuth(t *testing.T) {
+	_, admin, _ := startRun(t, "ADMIN_USER=admin", "ADMIN_PASSWORD=s3cret")
+
+	for _, path := range []string{"/startupz", "/healthz", "/readyz"} {
+		if status, _ := get(t, "http://"+admin+path); status != http.StatusOK {
+			t.Errorf("%s without credentials: status %d, want 200", path, status)
+		}
+	}
+	for _, path := range []string{"/metrics", "/status", "/config"} {
+		if status, _ := get(t, "http://"+admin+path); status != http.StatusUnauthorized {
+			t.Errorf("%s without credentials: status %d, want 401", path, status)
+		}
+		req, err := http.NewRequest("GET", "http://"+admin+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth("admin", "s3cret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s with credentials: status %d, want 200", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestValidateRequiresBothAdminCredentials(t *testing.T) {
+	for _, tt := range []struct{ user, password string }{{"admin", ""}, {"", "s3cret"}} {
+		cfg := defaultConfig()
+		cfg.AdminUser, cfg.AdminPassword = tt.user, tt.password
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate accepted user %q with password %q", tt.user, tt.password)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// registerChaos mounts endpoints for testing alerting and restarts:
+//
+//	POST /debug/fail-liveness     healthz starts returning 503
+//	POST /debug/recover-liveness  healthz returns 200 again
+func registerChaos(mux *http.ServeMux, st *state) {
+	mux.HandleFunc("POST /debug/fail-liveness", setLiveness(st, false))
+	mux.Handle("/debug/fail-liveness", methodNotAllowed(http.MethodPost))
+	mux.HandleFunc("POST /debug/recover-liveness", setLiveness(st, true))
+	mux.Handle("/debug/recover-liveness", methodNotAllowed(http.MethodPost))
+}
+
+func setLiveness(st *state, live bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st.unhealthy.Store(!live)
+		if live {
+			fmt.Fprintln(w, "liveness recovered")
+			return
+		}
+		fmt.Fprintln(w, "liveness failing")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+// post sends an empty POST request to url and returns the status code.
+func post(t *testing.T, url string) int {
+	t.Helper()
+	resp, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestChaos(t *testing.T) {
+	_, admin, _ := startRun(t, "ENABLE_CHAOS=true")
+
+	if status := post(t, "http://"+admin+"/debug/fail-liveness"); status != http.StatusOK {
+		t.Fatalf("fail-liveness: status %d, want 200", status)
+	}
+	if status, body := get(t, "http://"+admin+"/healthz"); status != http.StatusServiceUnavailable || body != "unhealthy\n" {
+		t.Errorf("healthz after fail-liveness: %d %q, want 503 \"unhealthy\"", status, body)
+	}
+	if status, _ := get(t, "http://"+admin+"/readyz"); status != http.StatusOK {
+		t.Errorf("readyz after fail-liveness: status %d, want 200", status)
+	}
+
+	if status := post(t, "http://"+admin+"/debug/recover-liveness"); status != http.StatusOK {
+		t.Fatalf("recover-liveness: status %d, want 200", status)
+	}
+	if status, body := get(t, "http://"+admin+"/healthz"); status != http.StatusOK || body != "ok\n" {
+		t.Errorf("healthz after recover-liveness: %d %q, want 200 \"ok\"", status, body)
+	}
+
+	if status, _ := get(t, "http://"+admin+"/debug/fail-liveness"); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET fail-liveness: status %d, want 405", status)
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	app, admin, _ := startRun(t)
+
+	for _, path := range []string{"/debug/fail-liveness", "/debug/recover-liveness"} {
+		if status := post(t, "http://"+admin+path); status != http.StatusNotFound {
+			t.Errorf("admin %s: status %d, want 404", path, status)
+		}
+		if status := post(t, "http://"+app+path); status != http.StatusNotFound {
+			t.Errorf("application %s: status %d, want 404", path, status)
+		}
+	}
+	if status, _ := get(t, "http://"+admin+"/healthz"); status != http.StatusOK {
+		t.Errorf("healthz: status %d, want 200", status)
+	}
+}
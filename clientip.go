@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// realIP determines the address of the client behind our ingress and makes
+// it available through clientIP.
+func realIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// resolveClientIP returns the client address of r. The X-Forwarded-For and
+// X-Real-IP headers are only honored when the direct peer is one of the
+// trusted proxies. The client is then the right-most X-Forwarded-For entry
+// that is not a trusted proxy itself, so clients cannot spoof their address
+// by sending the header themselves.
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	ip := remoteIP(r)
+	if addr, err := netip.ParseAddr(ip); err != nil || !isTrusted(addr) {
+		return ip
+	}
+
+	if hops := forwardedFor(r); len(hops) > 0 {
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(hops[i])
+			if err != nil {
+				break
+			}
+			ip = addr.Unmap().String()
+			if !isTrusted(addr) {
+				break
+			}
+		}
+		return ip
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
+
+// clientIP returns the address of the client that sent r, as determined by
+// realIP, falling back to the direct peer.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the address of the direct peer of r without the port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns all addresses listed in the X-Forwarded-For headers
+// of r, in order.
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	for _, tt := range []struct {
+		name   string
+		remote string
+		xff    string
+		realIP string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:4321", "", "", "203.0.113.7"},
+		{"spoofed forwarded-for from untrusted peer", "203.0.113.7:4321", "198.51.100.1", "", "203.0.113.7"},
+		{"spoofed real-ip from untrusted peer", "203.0.113.7:4321", "", "198.51.100.1", "203.0.113.7"},
+		{"forwarded-for from trusted proxy", "10.0.0.2:80", "198.51.100.1", "", "198.51.100.1"},
+		{"spoofed entry before the real client", "10.0.0.2:80", "192.0.2.99, 198.51.100.1", "", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:80", "198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"real-ip from trusted proxy", "10.0.0.2:80", "", "198.51.100.1", "198.51.100.1"},
+		{"garbage from trusted proxy", "10.0.0.2:80", "", "not-an-ip", "10.0.0.2"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := resolveClientIP(r, trusted); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort      = "8080"
+	defaultAdminPort = "8081"
+)
+
+// Config holds the server settings.
+type Config struct {
+	// Greeting is the message served on the index page.
+	Greeting string
+
+	// LogLevel is one of debug, info, warn or error and LogFormat one of
+	// json or text. Unknown values fall back to info and json.
+	LogLevel  string
+	LogFormat string
+
+	// StaticPath is where the embedded static assets are served. It must
+	// start and end with a slash.
+	StaticPath string
+
+	// Addr is the TCP address the application listens on, or the path of
+	// a Unix domain socket prefixed with "unix:".
+	Addr string
+
+	// AdminAddr is where health, readiness, metrics and profiling are
+	// served, apart from the application routes.
+	AdminAddr string
+
+	// IPFamily selects the IP versions TCP addresses listen on: dual,
+	// ipv4 or ipv6.
+	IPFamily string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// DisableKeepAlives closes every connection after its response, so
+	// clients reconnect and get balanced onto new pods.
+	DisableKeepAlives bool
+
+	// MaxConns limits the number of connections the application serves at
+	// once. Further connections wait in the listen backlog. Zero means no
+	// limit.
+	MaxConns int
+
+	// RequestTimeout is the time handlers get to produce a response. It
+	// must be shorter than WriteTimeout, or the connection is cut before
+	// the timeout response can be written. Zero
+	// disables the limit.
+	RequestTimeout time.Duration
+
+	// MaxBodyBytes limits the size of request bodies. Zero disables the
+	// limit.
+	MaxBodyBytes int64
+
+	// DrainDelay is how long the server keeps serving after readiness has
+	// been withdrawn, giving Kubernetes time to remove the pod from its
+	// Service endpoints before connections are refused.
+	DrainDelay time.Duration
+	// ShutdownTimeout is how long active requests get to finish once the
+	// shutdown has begun. Connections still open after that are closed.
+	ShutdownTimeout time.Duration
+
+	// TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// AllowedOrigins lists the origins browsers may call the application
+	// from. "*" allows every origin.
+	AllowedOrigins []string
+	// AllowCredentials lets cross-origin requests include cookies and
+	// authorization headers. It cannot be combined with "*".
+	AllowCredentials bool
+
+	// RateLimit is the number of requests per second allowed per client,
+	// with bursts of up to RateBurst requests. Zero disables rate limiting.
+	RateLimit float64
+	RateBurst int
+
+	// TrustedProxies are the networks of proxies whose X-Forwarded-For
+	// header is believed when determining the client address.
+	TrustedProxies []netip.Prefix
+
+	// ReadyChecks maps dependency names to TCP addresses that must be
+	// reachable for the pod to be ready, each checked within CheckTimeout.
+	ReadyChecks  map[string]string
+	CheckTimeout time.Duration
+
+	// AdminUser and AdminPassword protect the admin server with HTTP Basic
+	// authentication, apart from the probe endpoints. The admin server is
+	// open when both are empty. Fields tagged config:"secret" are redacted
+	// on /config.
+	AdminUser     string
+	AdminPassword string `config:"secret"`
+
+	// EnableH2C accepts cleartext HTTP/2 on the application server. Only
+	// use it on trusted internal networks.
+	EnableH2C bool
+
+	// EnablePprof mounts the profiling handlers under /debug/pprof/.
+	EnablePprof bool
+
+	// OTLPEndpoint is the collector traces are exported to. Tracing is off
+	// when it is empty.
+	OTLPEndpoint string
+
+	// EnableChaos mounts endpoints under /debug/ that make the liveness
+	// probe fail on demand.
+	EnableChaos bool
+}
+
+// defaultConfig returns the configuration used when nothing is overridden.
+func defaultConfig() Config {
+	return Config{
+		Greeting:          "Ahoi",
+		LogLevel:          "info",
+		LogFormat:         "json",
+		StaticPath:        "/static/",
+		Addr:              ":" + defaultPort,
+		AdminAddr:         ":" + defaultAdminPort,
+		IPFamily:          "dual",
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       15 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		RequestTimeout:    25 * time.Second,
+		MaxBodyBytes:      1 << 20,
+		DrainDelay:        5 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+		RateBurst:         10,
+		CheckTimeout:      2 * time.Second,
+	}
+}
+
+// loadFromEnv overrides c with the values set in the environment and in
+// CONFIG_FILE and validates the result.
+func (c *Config) loadFromEnv() error {
+	getenv := os.Getenv
+	if name := os.Getenv("CONFIG_FILE"); name != "" {
+		vars, err := readEnvFile(name)
+		if err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+		getenv = fileEnv(vars)
+	}
+
+	if v := getenv("LOG_LEVEL"); v != "" {
+		c.LogLevel = v
+	}
+	if v := getenv("LOG_FORMAT"); v != "" {
+		c.LogFormat = v
+	}
+	if v := getenv("GREETING"); v != "" {
+		c.Greeting = v
+	}
+
+	if v := getenv("STATIC_PATH"); v != "" {
+		c.StaticPath = v
+	}
+
+	var err error
+	if getenv("PORT") != "" || getenv("HOST") != "" {
+		if c.Addr, err = listenAddr(getenv, "PORT", defaultPort); err != nil {
+			return err
+		}
+	}
+	if v := getenv("LISTEN"); v != "" {
+		c.Addr = v
+	}
+	if getenv("ADMIN_PORT") != "" || getenv("HOST") != "" {
+		if c.AdminAddr, err = listenAddr(getenv, "ADMIN_PORT", defaultAdminPort); err != nil {
+			return err
+		}
+	}
+	if v := getenv("IP_FAMILY"); v != "" {
+		c.IPFamily = strings.ToLower(v)
+	}
+	if c.ReadHeaderTimeout, err = envDuration(getenv, "READ_HEADER_TIMEOUT", c.ReadHeaderTimeout); err != nil {
+		return err
+	}
+	if c.ReadTimeout, err = envDuration(getenv, "READ_TIMEOUT", c.ReadTimeout); err != nil {
+		return err
+	}
+	if c.WriteTimeout, err = envDuration(getenv, "WRITE_TIMEOUT", c.WriteTimeout); err != nil {
+		return err
+	}
+	if c.IdleTimeout, err = envDuration(getenv, "IDLE_TIMEOUT", c.IdleTimeout); err != nil {
+		return err
+	}
+	if c.DisableKeepAlives, err = envBool(getenv, "DISABLE_KEEPALIVES", c.DisableKeepAlives); err != nil {
+		return err
+	}
+	if c.MaxConns, err = envInt(getenv, "MAX_CONNS", c.MaxConns); err != nil {
+		return err
+	}
+	if c.RequestTimeout, err = envDuration(getenv, "REQUEST_TIMEOUT", c.RequestTimeout); err != nil {
+		return err
+	}
+	if c.MaxBodyBytes, err = envInt64(getenv, "MAX_BODY_BYTES", c.MaxBodyBytes); err != nil {
+		return err
+	}
+	if c.DrainDelay, err = envDuration(getenv, "DRAIN_DELAY", c.DrainDelay); err != nil {
+		return err
+	}
+	if c.ShutdownTimeout, err = envDuration(getenv, "SHUTDOWN_TIMEOUT", c.ShutdownTimeout); err != nil {
+		return err
+	}
+	c.TLSCertFile = getenv("TLS_CERT_FILE")
+	c.TLSKeyFile = getenv("TLS_KEY_FILE")
+	if v := getenv("ALLOWED_ORIGINS"); v != "" {
+		c.AllowedOrigins = c.AllowedOrigins[:0]
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, origin)
+			}
+		}
+	}
+	if c.AllowCredentials, err = envBool(getenv, "CORS_ALLOW_CREDENTIALS", c.AllowCredentials); err != nil {
+		return err
+	}
+	if c.RateLimit, err = envFloat(getenv, "RATE_LIMIT", c.RateLimit); err != nil {
+		return err
+	}
+	if c.RateBurst, err = envInt(getenv, "RATE_BURST", c.RateBurst); err != nil {
+		return err
+	}
+	if v := getenv("TRUSTED_PROXIES"); v != "" {
+		if c.TrustedProxies, err = parsePrefixes(v); err != nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES %q: %w", v, err)
+		}
+	}
+	if v := getenv("READY_CHECKS"); v != "" {
+		if c.ReadyChecks, err = parseChecks(v); err != nil {
+			return fmt.Errorf("invalid READY_CHECKS %q: %w", v, err)
+		}
+	}
+	if c.CheckTimeout, err = envDuration(getenv, "CHECK_TIMEOUT", c.CheckTimeout); err != nil {
+		return err
+	}
+	c.AdminUser = getenv("ADMIN_USER")
+	c.AdminPassword = getenv("ADMIN_PASSWORD")
+	if c.EnableH2C, err = envBool(getenv, "ENABLE_H2C", c.EnableH2C); err != nil {
+		return err
+	}
+	if c.EnablePprof, err = envBool(getenv, "ENABLE_PPROF", c.EnablePprof); err != nil {
+		return err
+	}
+	c.OTLPEndpoint = getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if c.EnableChaos, err = envBool(getenv, "ENABLE_CHAOS", c.EnableChaos); err != nil {
+		return err
+	}
+	return c.validate()
+}
+
+// useTLS reports whether the server should serve HTTPS.
+func (c Config) useTLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// network returns the network TCP listeners are opened on for the IP
+// family, or an empty string if the family is unknown.
+func (c Config) network() string {
+	switch c.IPFamily {
+	case "dual":
+		return "tcp"
+	case "ipv4":
+		return "tcp4"
+	case "ipv6":
+		return "tcp6"
+	}
+	return ""
+}
+
+// validate reports the first invalid setting in c.
+func (c Config) validate() error {
+	if len(c.StaticPath) < 2 || !strings.HasPrefix(c.StaticPath, "/") || !strings.HasSuffix(c.StaticPath, "/") ||
+		path.Clean(c.StaticPath)+"/" != c.StaticPath {
+		return fmt.Errorf("invalid static path %q: must be a clean path starting and ending with a slash", c.StaticPath)
+	}
+
+	for _, addr := range []string{c.Addr, c.AdminAddr} {
+		if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+			if path == "" {
+				return fmt.Errorf("invalid address %q: missing socket path", addr)
+			}
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+		if err := validPort(port); err != nil {
+			return fmt.Errorf("invalid address %q: %w", addr, err)
+		}
+	}
+	if c.Addr == c.AdminAddr {
+		return fmt.Errorf("admin server cannot share the address %q", c.Addr)
+	}
+	if c.network() == "" {
+		return fmt.Errorf("invalid IP family %q: must be dual, ipv4 or ipv6", c.IPFamily)
+	}
+
+	for _, t := range []struct {
+		name string
+		d    time.Duration
+	}{
+		{"read header timeout", c.ReadHeaderTimeout},
+		{"read timeout", c.ReadTimeout},
+		{"write timeout", c.WriteTimeout},
+		{"idle timeout", c.IdleTimeout},
+		{"request timeout", c.RequestTimeout},
+		{"drain delay", c.DrainDelay},
+		{"shutdown timeout", c.ShutdownTimeout},
+		{"check timeout", c.CheckTimeout},
+	} {
+		if t.d < 0 {
+			return fmt.Errorf("invalid %s %s: must not be negative", t.name, t.d)
+		}
+	}
+
+	if c.RequestTimeout > 0 && c.WriteTimeout > 0 && c.RequestTimeout >= c.WriteTimeout {
+		return fmt.Errorf("invalid request timeout %s: must be shorter than the write timeout %s", c.RequestTimeout, c.WriteTimeout)
+	}
+	if c.CheckTimeout <= 0 {
+		return fmt.Errorf("invalid check timeout %s: must be positive", c.CheckTimeout)
+	}
+	if c.MaxConns < 0 {
+		return fmt.Errorf("invalid maximum connections %d: must not be negative", c.MaxConns)
+	}
+	if c.MaxBodyBytes < 0 {
+		return fmt.Errorf("invalid maximum body size %d: must not be negative", c.MaxBodyBytes)
+	}
+
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid rate limit %v: must not be negative", c.RateLimit)
+	}
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		return fmt.Errorf("invalid rate burst %d: must be at least 1", c.RateBurst)
+	}
+
+	if c.AllowCredentials && slices.Contains(c.AllowedOrigins, "*") {
+		return fmt.Errorf("CORS credentials cannot be allowed for every origin")
+	}
+
+	if (c.AdminUser == "") != (c.AdminPassword == "") {
+		return fmt.Errorf("admin authentication needs both a user and a password")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	for _, name := range []string{c.TLSCertFile, c.TLSKeyFile} {
+		if name == "" {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("invalid TLS file: %w", err)
+		}
+		f.Close()
+	}
+	return nil
+}
+
+// fileEnv returns a lookup for the variables read from CONFIG_FILE, which
+// falls back to the environment for those the file does not set.
+func fileEnv(vars map[string]string) func(string) string {
+	return func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}
+}
+
+// readEnvFile reads KEY=VALUE lines from the file name. Blank lines and
+// lines starting with # are skipped.
+func readEnvFile(name string) (map[string]string, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("%s:%d: not of the form KEY=VALUE", name, i+1)
+		}
+		vars[k] = strings.TrimSpace(v)
+	}
+	return vars, nil
+}
+
+// listenAddr returns the address to bind to, built from the HOST and the
+// portKey variables looked up with getenv. It defaults to all interfaces on
+// def.
+func listenAddr(getenv func(string) string, portKey, def string) (string, error) {
+	port := getenv(portKey)
+	if port == "" {
+		port = def
+	}
+	if err := validPort(port); err != nil {
+		return "", fmt.Errorf("invalid %s %q: %w", portKey, port, err)
+	}
+	return net.JoinHostPort(getenv("HOST"), port), nil
+}
+
+func validPort(port string) error {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("port must be a number between 1 and 65535")
+	}
+	return nil
+}
+
+// envDuration parses the environment variable key as a time.Duration,
+// returning def when it is unset.
+func envDuration(getenv func(string) string, key string, def time.Duration) (time.Duration, error) {
+	v := getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	return d, nil
+}
+
+// envBool parses the environment variable key as a boolean, returning def
+// when it is unset.
+func envBool(getenv func(string) string, key string, def bool) (bool, error) {
+	v := getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: must be true or false", key, v)
+	}
+	return b, nil
+}
+
+// envInt parses the environment variable key as an integer, returning def
+// when it is unset.
+func envInt(getenv func(string) string, key string, def int) (int, error) {
+	v := getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a whole number", key, v)
+	}
+	return n, nil
+}
+
+// envInt64 parses the environment variable key as a 64-bit integer,
+// returning def when it is unset.
+func envInt64(getenv func(string) string, key string, def int64) (int64, error) {
+	v := getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a whole number", key, v)
+	}
+	return n, nil
+}
+
+// envFloat parses the environment variable key as a number, returning def
+// when it is unset.
+func envFloat(getenv func(string) string, key string, def float64) (float64, error) {
+	v := getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: must be a number", key, v)
+	}
+	return f, nil
+}
+
+// parsePrefixes parses a comma-separated list of CIDR ranges. Plain
+// addresses are taken as single-host ranges.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// parseChecks parses a comma-separated list of name=host:port pairs.
+func parseChecks(s string) (map[string]string, error) {
+	checks := make(map[string]string)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		name, addr, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%q is not of the form name=host:port", v)
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, err
+		}
+		checks[name] = addr
+	}
+	return checks, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestListenAddr(t *testing.T) {
+	for _, tt := range []struct {
+		port, host string
+		want       string
+		wantErr    bool
+	}{
+		{"", "", ":8080", false},
+		{"9090", "", ":9090", false},
+		{"9090", "127.0.0.1", "127.0.0.1:9090", false},
+		{"443", "::1", "[::1]:443", false},
+		{"0", "", "", true},
+		{"65536", "", "", true},
+		{"http", "", "", true},
+	} {
+		t.Setenv("PORT", tt.port)
+		t.Setenv("HOST", tt.host)
+
+		got, err := listenAddr(os.Getenv, "PORT", defaultPort)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("PORT=%q HOST=%q: error %v, want error %t", tt.port, tt.host, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("PORT=%q HOST=%q: got %q, want %q", tt.port, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFromEnvRejectsInvalidPort(t *testing.T) {
+	t.Setenv("PORT", "99999")
+
+	cfg := defaultConfig()
+	if err := cfg.loadFromEnv(); err == nil {
+		t.Error("loadFromEnv accepted PORT=99999")
+	}
+}
+
+func TestValidateRejectsZeroCheckTimeout(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.CheckTimeout = 0
+
+	if err := cfg.validate(); err == nil {
+		t.Error("validate accepted a check timeout of 0")
+	}
+}
+
+func TestValidateRequestTimeout(t *testing.T) {
+	cfg := defaultConfig()
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("default request timeout %s with write timeout %s: %v", cfg.RequestTimeout, cfg.WriteTimeout, err)
+	}
+
+	for _, tt := range []struct {
+		request, write time.Duration
+		ok             bool
+	}{
+		{29 * time.Second, 30 * time.Second, true},
+		{30 * time.Second, 30 * time.Second, false},
+		{time.Minute, 30 * time.Second, false},
+		{0, 30 * time.Second, true},
+		{time.Minute, 0, true},
+	} {
+		cfg := defaultConfig()
+		cfg.RequestTimeout, cfg.WriteTimeout = tt.request, tt.write
+		if err := cfg.validate(); (err == nil) != tt.ok {
+			t.Errorf("request timeout %s, write timeout %s: err %v, want ok %t", tt.request, tt.write, err, tt.ok)
+		}
+	}
+}
+
+func TestIPFamily(t *testing.T) {
+	for _, tt := range []struct {
+		family  string
+		network string
+	}{
+		{"", "tcp"},
+		{"dual", "tcp"},
+		{"ipv4", "tcp4"},
+		{"IPv6", "tcp6"},
+		{"ipv5", ""},
+	} {
+		if tt.family != "" {
+			t.Setenv("IP_FAMILY", tt.family)
+		}
+		cfg := defaultConfig()
+		err := cfg.loadFromEnv()
+		if got := cfg.network(); got != tt.network {
+			t.Errorf("IP_FAMILY=%q: network %q, want %q", tt.family, got, tt.network)
+		}
+		if (err == nil) != (tt.network != "") {
+			t.Errorf("IP_FAMILY=%q: err %v", tt.family, err)
+		}
+	}
+}
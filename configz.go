@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+)
+
+// configHandler shows the configuration in effect as JSON, defaults and
+// reloaded settings included. Keys are the camelCase field names of Config,
+// durations are shown in Go's duration syntax and fields tagged
+// config:"secret" are redacted once set.
+func configHandler(cfg *atomic.Pointer[Config]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v := reflect.ValueOf(*cfg.Load())
+		out := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			field, f := v.Type().Field(i), v.Field(i).Interface()
+			if d, ok := f.(time.Duration); ok {
+				f = d.String()
+			}
+			if field.Tag.Get("config") == "secret" && !v.Field(i).IsZero() {
+				f = "[redacted]"
+			}
+			out[camelCase(field.Name)] = f
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(out)
+	}
+}
+
+// camelCase lowercases the leading capitals of name, keeping the last one
+// of an initialism that starts a new word: IPFamily becomes ipFamily.
+func camelCase(name string) string {
+	r := []rune(name)
+	n := 0
+	for n < len(r) && unicode.IsUpper(r[n]) {
+		n++
+	}
+	if n > 1 && n < len(r) && unicode.IsLower(r[n]) {
+		n--
+	}
+	return strings.ToLower(string(r[:n])) + string(r[n:])
+}
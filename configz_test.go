@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConfigHandler(t *testing.T) {
+	c := defaultConfig()
+	c.AdminUser, c.AdminPassword = "admin", "s3cret"
+	var cfg atomic.Pointer[Config]
+	cfg.Store(&c)
+
+	rec := httptest.NewRecorder()
+	configHandler(&cfg)(rec, httptest.NewRequest("GET", "/config", nil))
+
+	if strings.Contains(rec.Body.String(), "s3cret") {
+		t.Fatalf("body leaks the password: %s", rec.Body)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]any{
+		"adminPassword":  "[redacted]",
+		"adminUser":      "admin",
+		"greeting":       "Ahoi",
+		"requestTimeout": "25s",
+		"ipFamily":       "dual",
+	} {
+		if got[k] != want {
+			t.Errorf("%s = %v, want %v", k, got[k], want)
+		}
+	}
+
+	c.AdminPassword = ""
+	rec = httptest.NewRecorder()
+	configHandler(&cfg)(rec, httptest.NewRequest("GET", "/config", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["adminPassword"] != "" {
+		t.Errorf("unset password shown as %v, want empty", got["adminPassword"])
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"Addr":         "addr",
+		"ReadTimeout":  "readTimeout",
+		"IPFamily":     "ipFamily",
+		"TLSCertFile":  "tlsCertFile",
+		"OTLPEndpoint": "otlpEndpoint",
+		"EnableH2C":    "enableH2C",
+		"TLS":          "tls",
+	} {
+		if got := camelCase(name); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRunConfigShowsEffectiveLogSettings(t *testing.T) {
+	_, admin, _ := startRun(t, "LOG_LEVEL=verbose", "LOG_FORMAT=logfmt")
+
+	status, body := get(t, "http://"+admin+"/config")
+	if status != http.StatusOK {
+		t.Fatalf("config: status %d, want 200", status)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["logLevel"] != "info" || got["logFormat"] != "json" {
+		t.Errorf("logLevel %v and logFormat %v, want info and json", got["logLevel"], got["logFormat"])
+	}
+}
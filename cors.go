@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const corsAllowMethods = "GET, HEAD, POST, PUT, PATCH, DELETE"
+
+// cors allows browsers on the given origins to call the application. An
+// origin of "*" allows any origin, but then credentials must be off, as
+// browsers reject that combination anyway. Without origins no CORS headers
+// are sent at all.
+func cors(origins []string, credentials bool) func(http.Handler) http.Handler {
+	wildcard := slices.Contains(origins, "*")
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if !wildcard {
+				w.Header().Add("Vary", "Origin")
+			}
+			if origin == "" || !wildcard && !slices.Contains(origins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			if wildcard {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if credentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+				if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				}
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.Set("Access-Control-Expose-Headers", requestIDHeader)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	allowed := "https://app.example.com"
+
+	for _, tt := range []struct {
+		name        string
+		origins     []string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+	}{
+		{"allowed origin", []string{allowed}, "GET", allowed, false, http.StatusOK, allowed},
+		{"disallowed origin", []string{allowed}, "GET", "https://evil.example.com", false, http.StatusOK, ""},
+		{"no origin", []string{allowed}, "GET", "", false, http.StatusOK, ""},
+		{"wildcard", []string{"*"}, "GET", "https://any.example.com", false, http.StatusOK, "*"},
+		{"preflight", []string{allowed}, "OPTIONS", allowed, true, http.StatusNoContent, allowed},
+		{"disallowed preflight", []string{allowed}, "OPTIONS", "https://evil.example.com", true, http.StatusOK, ""},
+	} {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		}
+		rec := httptest.NewRecorder()
+		cors(tt.origins, false)(ok).ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, got, tt.allowOrigin)
+		}
+		if tt.status == http.StatusNoContent {
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != corsAllowMethods {
+				t.Errorf("%s: Access-Control-Allow-Methods %q, want %q", tt.name, got, corsAllowMethods)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+				t.Errorf("%s: Access-Control-Allow-Headers %q, want Content-Type", tt.name, got)
+			}
+		}
+	}
+}
+
+func TestCORSRejectsCredentialsWithWildcard(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.AllowCredentials = true
+
+	if err := cfg.validate(); err == nil {
+		t.Error("validate accepted credentials for every origin")
+	}
+}
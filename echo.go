@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// redactedHeaders are never echoed back verbatim.
+var redactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"X-Api-Key",
+	"X-Auth-Token",
+}
+
+// echoMaxBytes caps the bodies echo reads when MAX_BODY_BYTES disables the
+// general limit, since the whole body is held in memory.
+const echoMaxBytes = 1 << 20
+
+// echo describes the request it received as JSON, which helps to see what
+// actually arrives through ingresses and proxies. Credentials are redacted.
+// Bodies larger than limit, or echoMaxBytes if limit <= 0, are rejected with
+// 413.
+func echo(limit int64) http.HandlerFunc {
+	if limit <= 0 {
+		limit = echoMaxBytes
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			writeError(w, http.StatusBadRequest)
+			return
+		}
+
+		headers := r.Header.Clone()
+		for _, k := range redactedHeaders {
+			if _, ok := headers[k]; ok {
+				headers[k] = []string{"[redacted]"}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(struct {
+			Method   string      `json:"method"`
+			Path     string      `json:"path"`
+			Query    url.Values  `json:"query"`
+			Headers  http.Header `json:"headers"`
+			Host     string      `json:"host"`
+			ClientIP string      `json:"clientIP"`
+			Body     string      `json:"body"`
+		}{r.Method, r.URL.Path, r.URL.Query(), headers, r.Host, clientIP(r), string(b)})
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEcho(t *testing.T) {
+	req := httptest.NewRequest("POST", "/echo?a=1&a=2", strings.NewReader("hello"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Custom", "kept")
+	rec := httptest.NewRecorder()
+	echo(0)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("body leaks credentials: %s", rec.Body)
+	}
+	var got struct {
+		Method  string              `json:"method"`
+		Path    string              `json:"path"`
+		Query   map[string][]string `json:"query"`
+		Headers http.Header         `json:"headers"`
+		Body    string              `json:"body"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Method != "POST" || got.Path != "/echo" || got.Body != "hello" {
+		t.Errorf("got %s %s with body %q, want POST /echo with body \"hello\"", got.Method, got.Path, got.Body)
+	}
+	if q := got.Query["a"]; len(q) != 2 || q[0] != "1" || q[1] != "2" {
+		t.Errorf("query a = %v, want [1 2]", q)
+	}
+	for k, want := range map[string]string{"Authorization": "[redacted]", "Cookie": "[redacted]", "X-Custom": "kept"} {
+		if v := got.Headers.Get(k); v != want {
+			t.Errorf("header %s = %q, want %q", k, v, want)
+		}
+	}
+}
+
+func TestEchoBodyErrors(t *testing.T) {
+	h := maxBytes(8)(echo(8))
+
+	// A chunked body announces no length, so only reading it hits the limit.
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("x", 9)))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized chunked body: status %d, want 413", rec.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/echo", iotest.ErrReader(iotest.ErrTimeout))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("failing body: status %d, want 400", rec.Code)
+	}
+}
+
+func TestEchoCapsBodyWithoutLimit(t *testing.T) {
+	// MAX_BODY_BYTES=0 turns maxBytes off, echo still stops reading.
+	h := maxBytes(0)(echo(0))
+
+	for _, tt := range []struct {
+		size   int
+		status int
+	}{
+		{echoMaxBytes, http.StatusOK},
+		{echoMaxBytes + 1, http.StatusRequestEntityTooLarge},
+	} {
+		req := httptest.NewRequest("POST", "/echo", strings.NewReader(strings.Repeat("x", tt.size)))
+		req.ContentLength = -1
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%d byte body: status %d, want %d", tt.size, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeError replies with a JSON error body like
+// {"error":"not found","status":404}.
+func writeError(w http.ResponseWriter, status int) {
+	setErrorHeaders(w.Header())
+	w.WriteHeader(status)
+	w.Write(errorBody(status))
+}
+
+func setErrorHeaders(h http.Header) {
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+}
+
+// errorBody returns the JSON body writeError sends for status.
+func errorBody(status int) []byte {
+	b, _ := json.Marshal(struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}{strings.ToLower(http.StatusText(status)), status})
+	return append(b, '\n')
+}
+
+// catchAll is the pattern notFound is registered on. It matches every path no
+// other pattern does.
+const catchAll = "/"
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound)
+}
+
+// methodNotAllowed returns a handler rejecting the request method, listing
+// allow as the methods accepted instead.
+func methodNotAllowed(allow ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		writeError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGet registers h for GET and HEAD requests to path. Every other method
+// is answered with a JSON 405 instead of falling through to notFound.
+func handleGet(mux *http.ServeMux, path string, h http.Handler) {
+	mux.Handle(http.MethodGet+" "+path, h)
+	mux.Handle(path, methodNotAllowed(http.MethodGet, http.MethodHead))
+}
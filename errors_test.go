@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandlers(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		message string
+	}{
+		{"notFound", notFound, http.StatusNotFound, "not found"},
+		{"methodNotAllowed", methodNotAllowed(http.MethodGet), http.StatusMethodNotAllowed, "method not allowed"},
+	} {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type %q, want application/json", tt.name, ct)
+		}
+		var body struct {
+			Error  string `json:"error"`
+			Status int    `json:"status"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if body.Error != tt.message || body.Status != tt.status {
+			t.Errorf("%s: body %+v, want error %q and status %d", tt.name, body, tt.message, tt.status)
+		}
+	}
+}
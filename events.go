@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// events streams the uptime and goroutine count as server-sent events, one
+// every interval, until the client disconnects or the server shuts down.
+func events(st *state, start time.Time, interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The stream outlives any write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		fw := newFlushWriter(w)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			b, err := json.Marshal(struct {
+				Uptime        string  `json:"uptime"`
+				UptimeSeconds float64 `json:"uptimeSeconds"`
+				Goroutines    int     `json:"goroutines"`
+			}{time.Since(start).Round(time.Second).String(), time.Since(start).Seconds(), runtime.NumGoroutine()})
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(fw, "event: status\ndata: %s\n\n", b); err != nil {
+				return
+			}
+
+			select {
+			case <-t.C:
+				if st.shuttingDown.Load() {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
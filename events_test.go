@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	returned := make(chan struct{})
+	h := events(new(state), time.Now(), 10*time.Millisecond)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(returned)
+		h(w, r)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q, want text/event-stream", ct)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	for n := 0; n < 2; {
+		if !sc.Scan() {
+			t.Fatalf("stream ended after %d events: %v", n, sc.Err())
+		}
+		if line := sc.Text(); line == "event: status" {
+			if !sc.Scan() || !strings.HasPrefix(sc.Text(), `data: {"uptime":`) {
+				t.Fatalf("event %d: data %q", n, sc.Text())
+			}
+			n++
+		}
+	}
+
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler still running after the client went away")
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "net/http"
+
+// flushWriter sends every write straight to the client instead of holding
+// it in the server's buffers, for streaming responses. It finds the
+// http.Flusher through any wrapping middleware; if there is none, flushing
+// does nothing.
+type flushWriter struct {
+	http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	return &flushWriter{ResponseWriter: w, rc: http.NewResponseController(w)}
+}
+
+func (w *flushWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	if err != nil {
+		return n, err
+	}
+	w.Flush()
+	return n, nil
+}
+
+// Flush sends buffered data to the client, if the underlying writer
+// supports it.
+func (w *flushWriter) Flush() {
+	w.rc.Flush()
+}
+
+func (w *flushWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlushWriterThroughMiddleware(t *testing.T) {
+	var flushedEarly bool
+	rec := httptest.NewRecorder()
+	h := logging(discard)(gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fw := newFlushWriter(w)
+		io.WriteString(fw, "data: 1\n\n")
+		flushedEarly = rec.Flushed
+	})))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(rec, req)
+
+	if !flushedEarly {
+		t.Error("write was not flushed through the middleware")
+	}
+}
+
+// plainWriter is a ResponseWriter that cannot flush.
+type plainWriter struct {
+	header http.Header
+	body   []byte
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { w.body = append(w.body, b...); return len(b), nil }
+func (w *plainWriter) WriteHeader(int)             {}
+
+func TestFlushWriterWithoutFlusher(t *testing.T) {
+	w := &plainWriter{header: http.Header{}}
+	fw := newFlushWriter(w)
+
+	if _, err := io.WriteString(fw, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	fw.Flush()
+	if string(w.body) != "hello" {
+		t.Errorf("body %q, want \"hello\"", w.body)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	for _, tt := range []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"GZIP", true},
+		{"gzip;q=0", false},
+		{"br", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"*;q=0, gzip", true},
+		{"gzip, *;q=0", true},
+		{"gzip;q=0, *", false},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set("Accept-Encoding", tt.header)
+		}
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	large := strings.Repeat("Ahoi! ", gzipMinSize)
+
+	for _, tt := range []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		compressed     bool
+	}{
+		{"large text", "gzip", "text/plain; charset=utf-8", large, true},
+		{"not accepted", "", "text/plain; charset=utf-8", large, false},
+		{"small body", "gzip", "text/plain; charset=utf-8", "Ahoi", false},
+		{"image", "gzip", "image/png", large, false},
+		{"svg", "gzip", "image/svg+xml", large, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+				io.WriteString(w, tt.body)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			resp := rec.Result()
+
+			if v := resp.Header.Get("Vary"); v != "Accept-Encoding" {
+				t.Errorf("Vary %q, want Accept-Encoding", v)
+			}
+			body := rec.Body.String()
+			if tt.compressed {
+				if ce := resp.Header.Get("Content-Encoding"); ce != "gzip" {
+					t.Fatalf("Content-Encoding %q, want gzip", ce)
+				}
+				if cl := resp.Header.Get("Content-Length"); cl != "" {
+					t.Errorf("Content-Length %q kept on a compressed body", cl)
+				}
+				body = gunzip(t, rec.Body)
+			} else {
+				if ce := resp.Header.Get("Content-Encoding"); ce != "" {
+					t.Errorf("Content-Encoding %q, want none", ce)
+				}
+				if cl := resp.Header.Get("Content-Length"); cl != strconv.Itoa(len(tt.body)) {
+					t.Errorf("Content-Length %q, want %d", cl, len(tt.body))
+				}
+			}
+			if body != tt.body {
+				t.Errorf("body of %d bytes, want %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestGzipMiddlewarePanic(t *testing.T) {
+	large := strings.Repeat("Ahoi! ", gzipMinSize)
+
+	t.Run("before writing", func(t *testing.T) {
+		h := recoverer(discard)(gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			panic("boom")
+		})))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status %d, want 500", rec.Code)
+		}
+		if ce := rec.Header().Get("Content-Encoding"); ce != "" {
+			t.Errorf("Content-Encoding %q on the error", ce)
+		}
+		if strings.Contains(rec.Body.String(), "partial") {
+			t.Errorf("body %q carries the buffered output of the handler", rec.Body)
+		}
+	})
+
+	t.Run("after flushing", func(t *testing.T) {
+		h := recoverer(discard)(gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, large)
+			http.NewResponseController(w).Flush()
+			panic("boom")
+		})))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if ce := rec.Header().Get("Content-Encoding"); ce != "gzip" {
+			t.Fatalf("Content-Encoding %q, want gzip", ce)
+		}
+		// The gzip stream is closed, so what was flushed decodes in full.
+		if body := gunzip(t, rec.Body); body != large {
+			t.Errorf("body of %d bytes, want %d", len(body), len(large))
+		}
+	})
+}
+
+// gunzip decompresses r, failing the test on a truncated or corrupt stream.
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
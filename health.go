@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// state is the lifecycle of one call of run, shared by the probes, the chaos
+// endpoints and the drain middleware.
+type state struct {
+	// started is set once initialization has completed and never cleared.
+	started atomic.Bool
+	// ready is true while the server accepts traffic. It is set once the
+	// listeners are up and cleared as soon as shutdown begins. Change it with
+	// setReady so the metrics follow.
+	ready atomic.Bool
+	// shuttingDown is set once shutdown has begun and never cleared.
+	shuttingDown atomic.Bool
+	// unhealthy is set by the chaos endpoints to make healthz fail as if the
+	// process had deadlocked.
+	unhealthy atomic.Bool
+}
+
+// setReady changes the readiness state, recording the transition in the
+// metrics if it actually changed.
+func (st *state) setReady(v bool) {
+	if !st.ready.CompareAndSwap(!v, v) {
+		return
+	}
+	if v {
+		appReady.Set(1)
+	} else {
+		appReady.Set(0)
+	}
+	appReadyTransitions.Inc()
+}
+
+// HealthChecker checks a dependency the application needs to serve traffic.
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// PingChecker checks that a TCP connection to Addr can be established.
+type PingChecker struct {
+	Addr string
+}
+
+func (p PingChecker) Check(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// healthz reports that the process is alive. It deliberately checks nothing
+// else, so a failing dependency never gets the pod restarted.
+func healthz(st *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := healthReport{Status: "ok", Checks: []checkResult{}}
+		if st.unhealthy.Load() {
+			report.Status = "unhealthy"
+		}
+		report.write(w, r, report.Status == "ok")
+	}
+}
+
+// startupz tells Kubernetes whether the pod has finished starting up, which
+// holds off the liveness probe until then.
+func startupz(st *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !st.started.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "started")
+	}
+}
+
+// readyz tells Kubernetes whether to route traffic to this pod. Once the
+// server is up, all checks are run concurrently, each limited to timeout,
+// and the pod is only ready if all of them pass.
+func readyz(st *state, checks map[string]HealthChecker, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := healthReport{Status: "not ready", Checks: []checkResult{}}
+		if st.ready.Load() {
+			report.Checks = runChecks(r.Context(), checks, timeout)
+			report.Status = "ready"
+			for _, c := range report.Checks {
+				if c.Status != "ok" {
+					report.Status = "not ready"
+				}
+			}
+		}
+		report.write(w, r, report.Status == "ready")
+	}
+}
+
+// healthReport is the detailed answer of a health endpoint.
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+type checkResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// write replies with the status as plain text for probes, followed by a
+// line for every failed check, or with the whole report as JSON if r asks
+// for ?verbose=true. Unless ok, the status code is 503.
+func (h healthReport) write(w http.ResponseWriter, r *http.Request, ok bool) {
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); !verbose {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		fmt.Fprintln(w, h.Status)
+		for _, c := range h.Checks {
+			if c.Status != "ok" {
+				fmt.Fprintf(w, "%s: %s\n", c.Name, c.Error)
+			}
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(h)
+}
+
+// runChecks runs checks concurrently and returns their results sorted by
+// name.
+func runChecks(ctx context.Context, checks map[string]HealthChecker, timeout time.Duration) []checkResult {
+	names := slices.Sorted(maps.Keys(checks))
+	results := make([]checkResult, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checks[name].Check(ctx)
+			results[i] = checkResult{Name: name, Status: "ok", Latency: time.Since(start).String()}
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+			}
+		})
+	}
+	wg.Wait()
+	return results
+}
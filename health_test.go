@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	mux := http.NewServeMux()
+	handleGet(mux, "/healthz", healthz(new(state)))
+
+	for _, tt := range []struct {
+		method string
+		status int
+		body   string
+	}{
+		{http.MethodGet, http.StatusOK, "ok\n"},
+		{http.MethodPost, http.StatusMethodNotAllowed, ""},
+		{http.MethodDelete, http.StatusMethodNotAllowed, ""},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tt.method, "/healthz", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s /healthz: status %d, want %d", tt.method, rec.Code, tt.status)
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s /healthz: body %q, want %q", tt.method, rec.Body, tt.body)
+		}
+	}
+}
+
+// checkFunc adapts a function to a HealthChecker.
+type checkFunc func(ctx context.Context) error
+
+func (f checkFunc) Check(ctx context.Context) error { return f(ctx) }
+
+func TestReadyz(t *testing.T) {
+	pass := checkFunc(func(context.Context) error { return nil })
+	fail := checkFunc(func(context.Context) error { return errors.New("connection refused") })
+	hang := checkFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	for _, tt := range []struct {
+		name   string
+		ready  bool
+		checks map[string]HealthChecker
+		status int
+		body   string
+	}{
+		{"not started", false, nil, http.StatusServiceUnavailable, "not ready\n"},
+		{"no checks", true, nil, http.StatusOK, "ready\n"},
+		{"passing check", true, map[string]HealthChecker{"db": pass}, http.StatusOK, "ready\n"},
+		{"failing check", true, map[string]HealthChecker{"db": pass, "cache": fail}, http.StatusServiceUnavailable, "not ready\ncache: connection refused\n"},
+		{"hanging check", true, map[string]HealthChecker{"db": hang}, http.StatusServiceUnavailable, "not ready\ndb: context deadline exceeded\n"},
+	} {
+		st := new(state)
+		st.ready.Store(tt.ready)
+		rec := httptest.NewRecorder()
+		readyz(st, tt.checks, 10*time.Millisecond)(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.name, rec.Code, rec.Body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestStartupz(t *testing.T) {
+	st := new(state)
+	rec := httptest.NewRecorder()
+	startupz(st)(rec, httptest.NewRequest("GET", "/startupz", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "starting\n" {
+		t.Errorf("before startup: %d %q, want 503 \"starting\"", rec.Code, rec.Body)
+	}
+
+	st.started.Store(true)
+	rec = httptest.NewRecorder()
+	startupz(st)(rec, httptest.NewRequest("GET", "/startupz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "started\n" {
+		t.Errorf("after startup: %d %q, want 200 \"started\"", rec.Code, rec.Body)
+	}
+}
+
+func TestReadyzVerbose(t *testing.T) {
+	st := new(state)
+	st.ready.Store(true)
+	checks := map[string]HealthChecker{
+		"db":    checkFunc(func(context.Context) error { return nil }),
+		"cache": checkFunc(func(context.Context) error { return errors.New("connection refused") }),
+	}
+	h := readyz(st, checks, time.Second)
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/readyz?verbose=true", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", ct)
+	}
+	var report healthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != "not ready" || len(report.Checks) != 2 {
+		t.Fatalf("report %+v, want not ready with 2 checks", report)
+	}
+	for i, want := range []checkResult{
+		{Name: "cache", Status: "failed", Error: "connection refused"},
+		{Name: "db", Status: "ok"},
+	} {
+		got := report.Checks[i]
+		if got.Name != want.Name || got.Status != want.Status || got.Error != want.Error {
+			t.Errorf("check %d: %+v, want %+v", i, got, want)
+		}
+		if _, err := time.ParseDuration(got.Latency); err != nil {
+			t.Errorf("check %d: latency %q: %v", i, got.Latency, err)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if body := rec.Body.String(); body != "not ready\ncache: connection refused\n" {
+		t.Errorf("plain body %q, want the status and the failed check", body)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(templates, "templates/index.html"))
+
+// index greets the client with an HTML page for browsers, as JSON if it asks
+// for it and as plain text otherwise. The greeting is loaded on every
+// request, so it can be swapped while running.
+func index(greetings *atomic.Pointer[string], static string, start time.Time, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		greeting := *greetings.Load()
+		w.Header().Add("Vary", "Accept")
+		switch negotiate(r, "text/plain", "application/json", "text/html") {
+		case "text/html":
+			var buf bytes.Buffer
+			err := indexTemplate.Execute(&buf, struct {
+				Greeting string
+				Static   string
+				Version  string
+				Uptime   time.Duration
+			}{greeting, static, version, time.Since(start).Round(time.Second)})
+			if err != nil {
+				logger.Error("rendering index", slog.Any("error", err))
+				writeError(w, http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			buf.WriteTo(w)
+		case "application/json":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				Message string `json:"message"`
+			}{greeting})
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			fmt.Fprintln(w, greeting)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIndexRouting(t *testing.T) {
+	app, _, _ := startRun(t)
+
+	for _, tt := range []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodGet, "/", http.StatusOK, ""},
+		{http.MethodHead, "/", http.StatusOK, ""},
+		{http.MethodPost, "/", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodDelete, "/", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{http.MethodGet, "/nope", http.StatusNotFound, ""},
+	} {
+		req, err := http.NewRequest(tt.method, "http://"+app+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+		if allow := resp.Header.Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+	}
+}
+
+func TestIndexGreetingFromEnv(t *testing.T) {
+	app, _, _ := startRun(t, "GREETING=Moin")
+
+	if status, body := get(t, "http://"+app+"/"); status != http.StatusOK || body != "Moin\n" {
+		t.Errorf("GET /: %d %q, want 200 \"Moin\"", status, body)
+	}
+}
+
+func TestIndexNegotiation(t *testing.T) {
+	var greetings atomic.Pointer[string]
+	greeting := "Hello"
+	greetings.Store(&greeting)
+	h := index(&greetings, "/static/", time.Now(), discard)
+
+	for _, tt := range []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "text/plain; charset=utf-8", "Hello\n"},
+		{"application/json", "application/json", `{"message":"Hello"}` + "\n"},
+		{"text/html", "text/html; charset=utf-8", "<h1>Hello</h1>"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+
+		if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("Accept %q: Content-Type %q, want %q", tt.accept, ct, tt.contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("Accept %q: body %q, want it to contain %q", tt.accept, rec.Body, tt.body)
+		}
+		if vary := rec.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("Accept %q: Vary %q, want Accept", tt.accept, vary)
+		}
+	}
+}
+
+func TestIndexHTMLEscapesGreeting(t *testing.T) {
+	var greetings atomic.Pointer[string]
+	greeting := `<script>alert("hi")</script>`
+	greetings.Store(&greeting)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	index(&greetings, "/static/", time.Now(), discard)(rec, req)
+
+	body := rec.Body.String()
+	if strings.Contains(body, "<script>") {
+		t.Errorf("body contains the unescaped greeting: %s", body)
+	}
+	if want := "<h1>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;</h1>"; !strings.Contains(body, want) {
+		t.Errorf("body does not contain %q: %s", want, body)
+	}
+	if !strings.Contains(body, "Version "+version) {
+		t.Errorf("body does not contain the version: %s", body)
+	}
+}
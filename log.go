@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// logFormat returns the format newLogger uses for format: text or json.
+func logFormat(format string) string {
+	if strings.ToLower(format) == "text" {
+		return "text"
+	}
+	return "json"
+}
+
+// newLogger returns a logger writing to w at the given level and format.
+// The level is stored in lvlVar, through which it can be changed while
+// running. Unknown values are replaced by info and json, with a warning
+// logged on the returned logger.
+func newLogger(w io.Writer, lvlVar *slog.LevelVar, level, format string) *slog.Logger {
+	var lvl slog.Level
+	badLevel := lvl.UnmarshalText([]byte(level)) != nil
+	if badLevel {
+		lvl = slog.LevelInfo
+	}
+	lvlVar.Set(lvl)
+
+	opts := &slog.HandlerOptions{Level: lvlVar}
+	var h slog.Handler
+	badFormat := logFormat(format) != strings.ToLower(format)
+	switch logFormat(format) {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		h = slog.NewJSONHandler(w, opts)
+	}
+
+	logger := slog.New(h)
+	if badLevel {
+		logger.Warn("unknown log level, using info", slog.String("log_level", level))
+	}
+	if badFormat {
+		logger.Warn("unknown log format, using json", slog.String("format", format))
+	}
+	return logger
+}
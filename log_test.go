@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerSuppressesDebugAtInfo(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, new(slog.LevelVar), "info", "json")
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("output %q, want only the info message", out)
+	}
+}
+
+func TestNewLoggerFallsBack(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, new(slog.LevelVar), "verbose", "logfmt")
+
+	var warnings []map[string]any
+	for line := range strings.Lines(buf.String()) {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("output is not JSON: %q", line)
+		}
+		warnings = append(warnings, entry)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("got %d log lines, want 2 warnings: %s", len(warnings), buf.String())
+	}
+	if warnings[0]["level"] != "WARN" || warnings[0]["log_level"] != "verbose" {
+		t.Errorf("level warning %v, want log_level verbose", warnings[0])
+	}
+	if warnings[1]["level"] != "WARN" || warnings[1]["format"] != "logfmt" {
+		t.Errorf("format warning %v, want format logfmt", warnings[1])
+	}
+
+	buf.Reset()
+	logger.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("debug message logged at the fallback level: %s", buf.String())
+	}
+}
+
+func TestNewLoggerFormat(t *testing.T) {
+	var buf bytes.Buffer
+	newLogger(&buf, new(slog.LevelVar), "info", "TEXT").Info("hello")
+
+	if out := buf.String(); !strings.Contains(out, "level=INFO msg=hello") {
+		t.Errorf("output %q, want text format", out)
+	}
+}
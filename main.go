@@ -1,23 +1,264 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/net/netutil"
+	"golang.org/x/sync/errgroup"
 )
 
-func index(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintln(w, "Ahoi")
+// serve runs s on l until ctx is done and then shuts it down, giving active
+// requests up to timeout to finish. Connections still open after that are
+// closed forcibly. It serves HTTPS when s has a TLS configuration.
+func serve(ctx context.Context, s *http.Server, l net.Listener, timeout time.Duration, logger *slog.Logger) error {
+	errc := make(chan error, 1)
+	go func() {
+		if s.TLSConfig != nil {
+			errc <- s.ServeTLS(l, "", "")
+			return
+		}
+		errc <- s.Serve(l)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.Shutdown(ctx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			logger.Warn("shutdown timed out, closing remaining connections",
+				slog.String("addr", l.Addr().String()),
+				slog.String("timeout", timeout.String()),
+			)
+			s.Close()
+		}
+		return fmt.Errorf("shutting down %s: %w", l.Addr(), err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func main() {
+// run wires up the application and the admin server and serves both until
+// ctx is done or one of them fails. On shutdown, readiness is withdrawn
+// first and new application requests are turned away with 503, while both
+// servers keep running for the drain delay so the load balancer can stop
+// sending traffic. Only then are they shut down. SIGHUP
+// reloads the configuration.
+func run(ctx context.Context) error {
+	start := time.Now()
+	cfg := defaultConfig()
+	err := cfg.loadFromEnv()
+
+	var logLevel slog.LevelVar
+	logger := newLogger(os.Stdout, &logLevel, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	// Record what the logger fell back to, so /config shows the settings
+	// actually in use.
+	cfg.LogLevel = strings.ToLower(logLevel.Level().String())
+	cfg.LogFormat = logFormat(cfg.LogFormat)
+
+	var cert *tls.Certificate
+	if cfg.useTLS() {
+		c, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return fmt.Errorf("%w: loading TLS certificate: %w", ErrConfig, err)
+		}
+		cert = &c
+	}
+
+	// Bind before anything starts goroutines, so a failure leaves nothing
+	// behind.
+	appLn, err := listen(cfg.network(), cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if cfg.MaxConns > 0 {
+		appLn = netutil.LimitListener(appLn, cfg.MaxConns)
+	}
+	adminLn, err := listen(cfg.network(), cfg.AdminAddr)
+	if err != nil {
+		appLn.Close()
+		return err
+	}
+
+	var (
+		current  atomic.Pointer[Config]
+		greeting atomic.Pointer[string]
+	)
+	current.Store(&cfg)
+	greeting.Store(&cfg.Greeting)
+	st := new(state)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", index)
+	mux.HandleFunc(catchAll, notFound)
+	handleGet(mux, "/{$}", index(&greeting, cfg.StaticPath, start, logger))
+	handleGet(mux, "/version", http.HandlerFunc(versionHandler))
+	mux.Handle("/echo", echo(cfg.MaxBodyBytes))
+	handleGet(mux, "/events", events(st, start, time.Second))
+	handleGet(mux, cfg.StaticPath, http.StripPrefix(strings.TrimSuffix(cfg.StaticPath, "/"), staticHandler()))
+
+	checks := make(map[string]HealthChecker)
+	for name, addr := range cfg.ReadyChecks {
+		checks[name] = PingChecker{Addr: addr}
+	}
+
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc(catchAll, notFound)
+	handleGet(adminMux, "/startupz", startupz(st))
+	handleGet(adminMux, "/healthz", healthz(st))
+	handleGet(adminMux, "/readyz", readyz(st, checks, cfg.CheckTimeout))
+	handleGet(adminMux, "/metrics", promhttp.Handler())
+	handleGet(adminMux, "/status", status(start))
+	handleGet(adminMux, "/config", configHandler(&current))
+	if cfg.EnablePprof {
+		registerPprof(adminMux)
+	}
+	if cfg.EnableChaos {
+		registerChaos(adminMux, st)
+	}
 
-	s := &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
+	var tp *sdktrace.TracerProvider
+	if cfg.OTLPEndpoint != "" {
+		if tp, err = newTracerProvider(ctx); err != nil {
+			appLn.Close()
+			adminLn.Close()
+			return fmt.Errorf("%w: setting up tracing: %w", ErrConfig, err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				logger.Warn("flushing traces", slog.Any("error", err))
+			}
+		}()
 	}
 
-	log.Fatal(s.ListenAndServe())
+	var h http.Handler = instrument(mux)
+	h = gzipMiddleware(h)
+	h = timeout(cfg.RequestTimeout, "/events")(h)
+	h = maxBytes(cfg.MaxBodyBytes)(h)
+	if cfg.RateLimit > 0 {
+		h = newRateLimiter(ctx, cfg.RateLimit, cfg.RateBurst).middleware(h)
+	}
+	h = drain(st)(h)
+	h = cors(cfg.AllowedOrigins, cfg.AllowCredentials)(h)
+	h = recoverer(logger)(h)
+	h = logging(logger)(h)
+	h = realIP(cfg.TrustedProxies)(h)
+	h = requestID(h)
+
+	if tp != nil {
+		h = tracing(mux, tp)(h)
+	}
+
+	var adminHandler http.Handler = basicAuth(cfg.AdminUser, cfg.AdminPassword, "/startupz", "/healthz", "/readyz")(adminMux)
+	adminHandler = recoverer(logger)(adminHandler)
+
+	errorLog := slog.NewLogLogger(logger.Handler(), slog.LevelInfo)
+	opts := []Option{WithHandler(h), WithErrorLog(errorLog)}
+	if cert != nil {
+		opts = append(opts, WithTLS(*cert))
+	}
+	if cfg.EnableH2C {
+		opts = append(opts, WithH2C())
+	}
+
+	app := NewServer(cfg, opts...)
+	admin := NewServer(cfg, WithAddr(cfg.AdminAddr), WithHandler(adminHandler), WithErrorLog(errorLog))
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	g, gctx := errgroup.WithContext(ctx)
+	stopping, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	g.Go(func() error {
+		return serve(stopping, app, appLn, cfg.ShutdownTimeout, logger)
+	})
+	g.Go(func() error {
+		return serve(stopping, admin, adminLn, cfg.ShutdownTimeout, logger)
+	})
+	g.Go(func() error {
+		<-gctx.Done()
+		st.setReady(false)
+		st.shuttingDown.Store(true)
+		if ctx.Err() != nil {
+			time.Sleep(cfg.DrainDelay)
+		}
+		stop()
+		return nil
+	})
+	g.Go(func() error {
+		for {
+			select {
+			case <-hup:
+				next := reload(logger, &logLevel, *current.Load(), &greeting)
+				current.Store(&next)
+			case <-gctx.Done():
+				return nil
+			}
+		}
+	})
+	st.started.Store(true)
+	st.setReady(true)
+
+	return g.Wait()
+}
+
+var (
+	// ErrConfig is returned by run for an invalid configuration.
+	ErrConfig = errors.New("invalid configuration")
+	// ErrBind is returned by run if a server cannot listen on its address.
+	ErrBind = errors.New("cannot listen")
+)
+
+// exitCode returns the exit status for the error returned by run: 2 for an
+// invalid configuration, 3 if binding a listener failed and 1 otherwise.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrConfig):
+		return 2
+	case errors.Is(err, ErrBind):
+		return 3
+	}
+	return 1
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx); err != nil {
+		logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+		logger.Error("exiting", slog.Any("error", err))
+		stop()
+		os.Exit(exitCode(err))
+	}
 }
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+// listenLoopback returns a listener on a free loopback port.
+func listenLoopback(t *testing.T) net.Listener {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// freeAddr returns a loopback address whose port was free a moment ago.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l := listenLoopback(t)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// startRun runs run on free loopback ports with the environment extended
+// by env, a list of KEY=VALUE pairs, and waits until it is ready. It returns
+// the addresses of the application and admin servers and a function
+// stopping run and returning its error.
+func startRun(t *testing.T, env ...string) (app, admin string, stop func() error) {
+	t.Helper()
+	app, admin = freeAddr(t), freeAddr(t)
+	_, port, _ := net.SplitHostPort(app)
+	_, adminPort, _ := net.SplitHostPort(admin)
+	t.Setenv("HOST", "127.0.0.1")
+	t.Setenv("PORT", port)
+	t.Setenv("ADMIN_PORT", adminPort)
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("DRAIN_DELAY", "0s")
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		t.Setenv(k, v)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var runErr error
+	go func() {
+		runErr = run(ctx)
+		close(done)
+	}()
+	stop = sync.OnceValue(func() error {
+		cancel()
+		// Shutdown waits up to 5s for connections the client dialed but
+		// never sent a request on, so keep closing those.
+		for {
+			http.DefaultClient.CloseIdleConnections()
+			select {
+			case <-done:
+				return runErr
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	})
+	t.Cleanup(func() { stop() })
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		select {
+		case <-done:
+			t.Fatalf("run: %v", runErr)
+		default:
+		}
+		if resp, err := http.Get("http://" + admin + "/readyz"); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return app, admin, stop
+			}
+		}
+	}
+	t.Fatal("run did not become ready")
+	return
+}
+
+// get fetches url and returns the status code and body.
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServeWaitsForInFlightRequests(t *testing.T) {
+	l := listenLoopback(t)
+	addr := l.Addr().String()
+
+	inFlight := make(chan struct{})
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inFlight)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, s, l, 5*time.Second, discard) }()
+
+	type result struct {
+		resp *http.Response
+		body string
+		err  error
+	}
+	res := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil {
+			res <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		res <- result{resp, string(b), err}
+	}()
+
+	<-inFlight
+	cancel()
+
+	r := <-res
+	if r.err != nil {
+		t.Fatalf("in-flight request failed: %v", r.err)
+	}
+	if r.resp.StatusCode != http.StatusOK || r.body != "done" {
+		t.Errorf("in-flight request got %d %q, want 200 \"done\"", r.resp.StatusCode, r.body)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve returned %v, want nil", err)
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("new connection accepted after shutdown")
+	}
+}
+
+func TestRunReadiness(t *testing.T) {
+	_, admin, stop := startRun(t, "DRAIN_DELAY=500ms")
+
+	if status, body := get(t, "http://"+admin+"/readyz"); status != http.StatusOK || body != "ready\n" {
+		t.Fatalf("readyz while running: %d %q, want 200 \"ready\"", status, body)
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- stop() }()
+
+	// Readiness is withdrawn right away while the servers are still up.
+	deadline := time.Now().Add(250 * time.Millisecond)
+	for {
+		status, body := get(t, "http://"+admin+"/readyz")
+		if status == http.StatusServiceUnavailable && body == "not ready\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("readyz during shutdown: %d %q, want 503 \"not ready\"", status, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("run returned %v, want nil", err)
+	}
+}
+
+func TestRunDrainsBeforeShutdown(t *testing.T) {
+	const delay = 300 * time.Millisecond
+	app, admin, stop := startRun(t, "DRAIN_DELAY="+delay.String())
+
+	begin := time.Now()
+	errc := make(chan error, 1)
+	go func() { errc <- stop() }()
+
+	// Readiness goes first.
+	for {
+		if status, _ := get(t, "http://"+admin+"/readyz"); status == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Connections are still accepted during the drain delay.
+	if time.Since(begin) < delay/2 {
+		conn, err := net.Dial("tcp", app)
+		if err != nil {
+			t.Fatalf("connection refused during the drain delay: %v", err)
+		}
+		conn.Close()
+	}
+	// And only then the servers shut down.
+	if err := <-errc; err != nil {
+		t.Fatalf("run returned %v, want nil", err)
+	}
+	if elapsed := time.Since(begin); elapsed < delay {
+		t.Errorf("shut down after %s, before the drain delay of %s", elapsed, delay)
+	}
+	if conn, err := net.Dial("tcp", app); err == nil {
+		conn.Close()
+		t.Error("connection accepted after shutdown")
+	}
+}
+
+func TestServeForcesCloseAfterTimeout(t *testing.T) {
+	l := listenLoopback(t)
+	addr := l.Addr().String()
+
+	inFlight, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	s := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(inFlight)
+		<-release
+	})}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, s, l, 50*time.Millisecond, logger) }()
+
+	reqErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err == nil {
+			resp.Body.Close()
+		}
+		reqErr <- err
+	}()
+
+	<-inFlight
+	cancel()
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("serve returned %v, want deadline exceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after the shutdown timeout")
+	}
+	if err := <-reqErr; err == nil {
+		t.Error("stuck request completed, want its connection closed")
+	}
+	if !strings.Contains(logs.String(), "shutdown timed out") {
+		t.Errorf("logs %q, want a shutdown timeout warning", logs.String())
+	}
+}
+
+func TestRunStartupz(t *testing.T) {
+	_, admin, _ := startRun(t)
+
+	if status, body := get(t, "http://"+admin+"/startupz"); status != http.StatusOK || body != "started\n" {
+		t.Errorf("startupz once ready: %d %q, want 200 \"started\"", status, body)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("boom"), 1},
+		{fmt.Errorf("%w: invalid port", ErrConfig), 2},
+		{fmt.Errorf("%w on :80: permission denied", ErrBind), 3},
+		{fmt.Errorf("starting: %w", fmt.Errorf("%w on :80", ErrBind)), 3},
+	} {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	taken := listenLoopback(t)
+	defer taken.Close()
+	_, takenPort, _ := net.SplitHostPort(taken.Addr().String())
+
+	for _, tt := range []struct {
+		name string
+		env  []string
+		want error
+	}{
+		{"invalid port", []string{"PORT=99999"}, ErrConfig},
+		{"missing TLS key", []string{"TLS_CERT_FILE=/nonexistent.crt", "TLS_KEY_FILE=/nonexistent.key"}, ErrConfig},
+		{"application port in use", []string{"LISTEN=" + taken.Addr().String()}, ErrBind},
+		{"admin port in use", []string{"ADMIN_PORT=" + takenPort}, ErrBind},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app, admin := freeAddr(t), freeAddr(t)
+			_, adminPort, _ := net.SplitHostPort(admin)
+			t.Setenv("HOST", "127.0.0.1")
+			t.Setenv("LISTEN", app)
+			t.Setenv("ADMIN_PORT", adminPort)
+			t.Setenv("LOG_LEVEL", "error")
+			for _, kv := range tt.env {
+				k, v, _ := strings.Cut(kv, "=")
+				t.Setenv(k, v)
+			}
+
+			err := run(t.Context())
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("run returned %v, want %v", err, tt.want)
+			}
+			// Nothing is left listening after a failed start.
+			if addr := os.Getenv("LISTEN"); addr != taken.Addr().String() {
+				l, err := net.Listen("tcp", addr)
+				if err != nil {
+					t.Errorf("application address still in use: %v", err)
+				} else {
+					l.Close()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route and status code.",
+	}, []string{"method", "path", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	appReady = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "app_ready",
+		Help: "Whether the application is ready to serve traffic (1) or not (0).",
+	})
+
+	appReadyTransitions = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "app_ready_transitions_total",
+		Help: "Number of times the readiness state changed.",
+	})
+)
+
+// instrument records request metrics for every route on mux. Requests are
+// labeled with the pattern they matched rather than the raw URL path, which
+// keeps the number of series bounded.
+func instrument(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		path := route(mux, r)
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		mux.ServeHTTP(rw, r)
+
+		httpRequests.WithLabelValues(r.Method, path, strconv.Itoa(rw.status)).Inc()
+		httpDuration.WithLabelValues(r.Method, path).Observe(time.Since(start).Seconds())
+	})
+}
+
+// route returns the path of the pattern on mux that r matches, without the
+// method, which is labeled separately. Requests matching no pattern, or only
+// the notFound catch-all, are reported as unmatched.
+func route(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" || pattern == catchAll {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
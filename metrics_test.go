@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestRoute(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", noop)
+	mux.HandleFunc("GET /items/{id}", noop)
+	mux.HandleFunc(catchAll, noop)
+
+	for _, tt := range []struct {
+		method, path string
+		want         string
+	}{
+		{"GET", "/metrics", "/metrics"},
+		{"GET", "/items/42", "/items/{id}"},
+		{"HEAD", "/items/42", "/items/{id}"},
+		{"GET", "/nope", "unmatched"},
+	} {
+		if got := route(mux, httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: route %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+// metricValue returns the current value of a gauge or counter.
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatal(err)
+	}
+	if g := pb.GetGauge(); g != nil {
+		return g.GetValue()
+	}
+	return pb.GetCounter().GetValue()
+}
+
+func TestSetReadyMetrics(t *testing.T) {
+	st := new(state)
+	appReady.Set(0)
+	defer st.setReady(false)
+	transitions := metricValue(t, appReadyTransitions)
+
+	for _, tt := range []struct {
+		ready       bool
+		gauge       float64
+		transitions float64
+	}{
+		{true, 1, 1},
+		{true, 1, 1},
+		{false, 0, 2},
+		{false, 0, 2},
+		{true, 1, 3},
+	} {
+		st.setReady(tt.ready)
+		if got := metricValue(t, appReady); got != tt.gauge {
+			t.Errorf("setReady(%t): app_ready %v, want %v", tt.ready, got, tt.gauge)
+		}
+		if got := metricValue(t, appReadyTransitions) - transitions; got != tt.transitions {
+			t.Errorf("setReady(%t): %v transitions, want %v", tt.ready, got, tt.transitions)
+		}
+	}
+}
+
+func TestRunExposesReadiness(t *testing.T) {
+	_, admin, _ := startRun(t)
+
+	status, body := get(t, "http://"+admin+"/metrics")
+	if status != http.StatusOK {
+		t.Fatalf("metrics: status %d, want 200", status)
+	}
+	if !strings.Contains(body, "\napp_ready 1\n") {
+		t.Errorf("metrics do not report app_ready 1:\n%s", body)
+	}
+}
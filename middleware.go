@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// responseWriter records the status code and body size written by a handler.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush sends everything written so far to the client.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logging writes one structured log line per request to logger.
+func logging(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rw, r)
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+				slog.String("request_id", RequestIDFromContext(r.Context())),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Int("size", rw.size),
+				slog.String("client_ip", clientIP(r)),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			)
+		})
+	}
+}
+
+// bodyHeaders describe the body a handler meant to send. They are dropped
+// before a panic is answered with an error body of our own.
+var bodyHeaders = []string{"Content-Length", "Content-Encoding", "Content-Disposition", "Content-Range", "Etag", "Last-Modified"}
+
+// recoverer turns a panicking handler into a 500 response instead of letting
+// it take down the connection. The panic is logged to logger.
+func recoverer(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				logger.Error("panic serving request",
+					slog.String("request_id", RequestIDFromContext(r.Context())),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Any("error", err),
+					slog.String("stack", string(debug.Stack())),
+				)
+				if !rw.wroteHeader {
+					for _, k := range bodyHeaders {
+						rw.Header().Del(k)
+					}
+					writeError(rw, http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// timeout cancels requests that take longer than d, answering them with
+// 503. The request context is cancelled so downstream work stops. Clients
+// may ask for a shorter timeout with the X-Request-Timeout header, but never
+// for a longer one. Requests to the paths in except, like long-lived streams,
+// are never timed out.
+func timeout(d time.Duration, except ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(except, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if d := requestTimeout(r, d); d > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), d)
+				defer cancel()
+				th := http.TimeoutHandler(next, d, timeoutBody)
+				th.ServeHTTP(&timeoutWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requestTimeout returns the timeout requested by the X-Request-Timeout
+// header of r, capped at limit. Without a valid header it returns limit.
+// A limit of zero or less means none.
+func requestTimeout(r *http.Request, limit time.Duration) time.Duration {
+	v := r.Header.Get(requestTimeoutHeader)
+	if v == "" {
+		return limit
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return limit
+	}
+	if limit > 0 && d > limit {
+		return limit
+	}
+	return d
+}
+
+var timeoutBody = string(errorBody(http.StatusServiceUnavailable))
+
+// timeoutWriter adds the JSON error headers to the 503 http.TimeoutHandler
+// writes once ctx has expired, which would otherwise go out without a
+// content type.
+type timeoutWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (w *timeoutWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && errors.Is(w.ctx.Err(), context.DeadlineExceeded) &&
+		w.Header().Get("Content-Type") == "" {
+		setErrorHeaders(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// maxBytes limits request bodies to n bytes. Requests announcing a larger
+// body are rejected with 413 right away, and handlers reading past the limit
+// get an *http.MaxBytesError.
+func maxBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				w.Header().Set("Connection", "close")
+				writeError(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// drain answers requests arriving after shutdown has begun with 503, telling
+// clients that ignore readiness to retry, preferably on another pod.
+// Requests already in flight are left to complete.
+func drain(st *state) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if st.shuttingDown.Load() {
+				w.Header().Set("Connection", "close")
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverer(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "5000")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="report.csv"`)
+		panic("boom")
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	ts := httptest.NewServer(recoverer(discard)(mux))
+	defer ts.Close()
+
+	if status, _ := get(t, ts.URL+"/panic"); status != http.StatusInternalServerError {
+		t.Errorf("panicking handler: status %d, want 500", status)
+	}
+	if status, _ := get(t, ts.URL+"/ok"); status != http.StatusOK {
+		t.Errorf("request after the panic: status %d, want 200", status)
+	}
+
+	resp, err := http.Get(ts.URL + "/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("panic after setting headers: status %d, want 500", resp.StatusCode)
+	}
+	for _, k := range []string{"Content-Encoding", "Content-Disposition"} {
+		if v := resp.Header.Get(k); v != "" {
+			t.Errorf("panic after setting headers: %s %q left on the error", k, v)
+		}
+	}
+	if resp.ContentLength == 5000 {
+		t.Error("panic after setting headers: Content-Length of the handler left on the error")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	canceled := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		canceled <- r.Context().Err()
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("handler has no deadline")
+		}
+	})
+	h := timeout(20 * time.Millisecond)(mux)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow handler: status %d, want 503", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("slow handler: Content-Type %q, want application/json", ct)
+	}
+	if body := rec.Body.String(); body != timeoutBody {
+		t.Errorf("slow handler: body %q, want %q", body, timeoutBody)
+	}
+	if err := <-canceled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("slow handler context: %v, want deadline exceeded", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("fast handler: status %d, want 200", rec.Code)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	const limit = 16
+	h := maxBytes(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var maxErr *http.MaxBytesError
+			if !errors.As(err, &maxErr) {
+				t.Errorf("read error %v, want *http.MaxBytesError", err)
+			}
+			writeError(w, http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	for _, tt := range []struct {
+		name    string
+		size    int
+		chunked bool
+		status  int
+	}{
+		{"under the limit", limit - 1, false, http.StatusOK},
+		{"at the limit", limit, false, http.StatusOK},
+		{"over the limit", limit + 1, false, http.StatusRequestEntityTooLarge},
+		{"chunked at the limit", limit, true, http.StatusOK},
+		{"chunked over the limit", limit + 1, true, http.StatusRequestEntityTooLarge},
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("x", tt.size)))
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	inFlight, release := make(chan struct{}), make(chan struct{})
+	st := new(state)
+	ts := httptest.NewServer(drain(st)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(inFlight)
+			<-release
+		}
+		io.WriteString(w, "done")
+	})))
+	defer ts.Close()
+
+	type result struct {
+		status int
+		body   string
+		err    error
+	}
+	res := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/slow")
+		if err != nil {
+			res <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		res <- result{resp.StatusCode, string(b), err}
+	}()
+	<-inFlight
+	st.shuttingDown.Store(true)
+
+	resp, err := http.Get(ts.URL + "/new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("new request while draining: status %d, want 503", resp.StatusCode)
+	}
+	if ra := resp.Header.Get("Retry-After"); ra != "1" {
+		t.Errorf("Retry-After %q, want 1", ra)
+	}
+	if !resp.Close {
+		t.Error("connection kept alive while draining")
+	}
+
+	close(release)
+	if r := <-res; r.err != nil || r.status != http.StatusOK || r.body != "done" {
+		t.Errorf("in-flight request: %d %q %v, want 200 \"done\"", r.status, r.body, r.err)
+	}
+}
+
+func TestRequestTimeoutHeader(t *testing.T) {
+	const limit = 10 * time.Second
+
+	for _, tt := range []struct {
+		header string
+		limit  time.Duration
+		want   time.Duration
+	}{
+		{"", limit, limit},
+		{"2s", limit, 2 * time.Second},
+		{"500ms", limit, 500 * time.Millisecond},
+		{"1m", limit, limit},
+		{"-1s", limit, limit},
+		{"0s", limit, limit},
+		{"soon", limit, limit},
+		{"1m", 0, time.Minute},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			r.Header.Set(requestTimeoutHeader, tt.header)
+		}
+		if got := requestTimeout(r, tt.limit); got != tt.want {
+			t.Errorf("%s %q with limit %s: got %s, want %s", requestTimeoutHeader, tt.header, tt.limit, got, tt.want)
+		}
+	}
+
+	var deadline time.Time
+	h := timeout(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	}))
+	for _, tt := range []struct {
+		header string
+		want   time.Duration
+	}{
+		{"100ms", 100 * time.Millisecond},
+		{"1h", limit},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(requestTimeoutHeader, tt.header)
+		begin := time.Now()
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if got := deadline.Sub(begin); got < tt.want || got > tt.want+time.Second {
+			t.Errorf("%s %q: deadline in %s, want %s", requestTimeoutHeader, tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// negotiate returns the media type from offers that the client prefers
+// according to the Accept header of r. Ties, and requests without an Accept
+// header, go to the offer listed first.
+func negotiate(r *http.Request, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0]
+	}
+
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality the Accept header values give to the
+// media type offer, using the most specific matching range.
+func acceptQuality(accept []string, offer string) float64 {
+	typ, _, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, v := range accept {
+		for _, rng := range strings.Split(v, ",") {
+			mt, params, _ := strings.Cut(rng, ";")
+			mt = strings.ToLower(strings.TrimSpace(mt))
+
+			var s int
+			switch {
+			case mt == offer:
+				s = 2
+			case mt == typ+"/*":
+				s = 1
+			case mt == "*/*":
+				s = 0
+			default:
+				continue
+			}
+			if s < specificity {
+				continue
+			}
+			specificity, q = s, rangeQuality(params)
+		}
+	}
+	return q
+}
+
+// rangeQuality returns the q parameter of a media range, defaulting to 1.
+func rangeQuality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.TrimSpace(k) != "q" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return q
+		}
+	}
+	return 1
+}
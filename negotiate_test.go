@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"text/plain", "application/json", "text/html"}
+
+	for _, tt := range []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/plain"},
+		{"*/*", "text/plain"},
+		{"application/json", "application/json"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html"},
+		{"text/*", "text/plain"},
+		{"text/*;q=0.5, application/json", "application/json"},
+		{"application/json;q=0.2, text/html;q=0.8", "text/html"},
+		{"text/plain;q=0, */*", "application/json"},
+		{"APPLICATION/JSON", "application/json"},
+		{"image/png", "text/plain"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := negotiate(r, offers...); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
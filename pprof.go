@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof mounts the runtime profiling handlers:
+//
+//	/debug/pprof/         index and the named profiles (allocs, block,
+//	                      goroutine, heap, mutex, threadcreate)
+//	/debug/pprof/cmdline  command line of the running program
+//	/debug/pprof/profile  CPU profile, ?seconds=N
+//	/debug/pprof/symbol   symbol lookup for program counters
+//	/debug/pprof/trace    execution trace, ?seconds=N
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPprof(t *testing.T) {
+	for _, tt := range []struct {
+		enable string
+		status int
+	}{
+		{"false", http.StatusNotFound},
+		{"true", http.StatusOK},
+	} {
+		t.Run("ENABLE_PPROF="+tt.enable, func(t *testing.T) {
+			app, admin, _ := startRun(t, "ENABLE_PPROF="+tt.enable)
+
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap"} {
+				if status, _ := get(t, "http://"+admin+path); status != tt.status {
+					t.Errorf("admin %s: status %d, want %d", path, status, tt.status)
+				}
+				if status, _ := get(t, "http://"+app+path); status != http.StatusNotFound {
+					t.Errorf("application %s: status %d, want 404", path, status)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// limiterIdle is how long a client may stay silent before its limiter
+	// is dropped.
+	limiterIdle = 3 * time.Minute
+
+	// evictInterval is how often idle limiters are looked for.
+	evictInterval = time.Minute
+)
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter hands out a token bucket per client IP.
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+}
+
+// newRateLimiter returns a limiter allowing each client limit requests per
+// second with bursts of up to burst requests. Idle clients are evicted until
+// ctx is done.
+func newRateLimiter(ctx context.Context, limit float64, burst int) *rateLimiter {
+	l := &rateLimiter{
+		limit:   rate.Limit(limit),
+		burst:   burst,
+		idle:    limiterIdle,
+		clients: make(map[string]*clientLimiter),
+	}
+	go l.evict(ctx, evictInterval)
+	return l
+}
+
+func (l *rateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &clientLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = time.Now()
+	return c.Limiter
+}
+
+// evict drops the limiters of clients idle for longer than l.idle, checking
+// every interval until ctx is done.
+func (l *rateLimiter) evict(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			l.mu.Lock()
+			for ip, c := range l.clients {
+				if now.Sub(c.lastSeen) > l.idle {
+					delete(l.clients, ip)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// middleware rejects requests from clients over their limit with 429 and
+// tells them when to retry.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := l.get(clientIP(r)).Reserve()
+		if delay := res.Delay(); !res.OK() || delay > 0 {
+			res.Cancel()
+			retry := int(math.Ceil(delay.Seconds()))
+			if !res.OK() || retry < 1 {
+				retry = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			writeError(w, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
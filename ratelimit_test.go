@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// One request every two seconds with bursts of two, and limiters
+	// evicted after 50ms of silence.
+	l := &rateLimiter{
+		limit:   rate.Limit(0.5),
+		burst:   2,
+		idle:    50 * time.Millisecond,
+		clients: make(map[string]*clientLimiter),
+	}
+	go l.evict(ctx, 10*time.Millisecond)
+	h := l.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := do("192.0.2.1"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: status %d, want 200", i+1, rec.Code)
+		}
+	}
+	rec := do("192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the burst: status %d, want 429", rec.Code)
+	}
+	if retry := rec.Header().Get("Retry-After"); retry != "2" {
+		t.Errorf("Retry-After %q, want 2", retry)
+	}
+
+	// Every client has its own bucket.
+	if rec := do("192.0.2.2"); rec.Code != http.StatusOK {
+		t.Errorf("request from another client: status %d, want 200", rec.Code)
+	}
+
+	// Once idle, clients are forgotten and start with a full bucket again.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		l.mu.Lock()
+		n := len(l.clients)
+		l.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d idle limiters left, want all evicted", n)
+		}
+	}
+	if rec := do("192.0.2.1"); rec.Code != http.StatusOK {
+		t.Errorf("request after eviction: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// reload reads the configuration again and applies the settings that can
+// change while running: the greeting and the log level, which it stores in
+// logLevel. Every other setting needs a restart, so changes to them are only
+// logged. It returns the configuration now in effect, which is current if
+// the new one is invalid.
+func reload(logger *slog.Logger, logLevel *slog.LevelVar, current Config, greeting *atomic.Pointer[string]) Config {
+	next := defaultConfig()
+	if err := next.loadFromEnv(); err != nil {
+		logger.Error("reloading configuration, keeping the current one", slog.Any("error", err))
+		return current
+	}
+
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(next.LogLevel)); err != nil {
+		logger.Warn("unknown log level, keeping the current one", slog.String("log_level", next.LogLevel))
+		next.LogLevel = current.LogLevel
+	} else {
+		logLevel.Set(lvl)
+		next.LogLevel = strings.ToLower(lvl.String())
+	}
+	next.LogFormat = logFormat(next.LogFormat)
+	greeting.Store(&next.Greeting)
+
+	applied := current
+	applied.Greeting = next.Greeting
+	applied.LogLevel = next.LogLevel
+
+	var ignored []string
+	av, nv := reflect.ValueOf(applied), reflect.ValueOf(next)
+	for i := range av.NumField() {
+		if !reflect.DeepEqual(av.Field(i).Interface(), nv.Field(i).Interface()) {
+			ignored = append(ignored, av.Type().Field(i).Name)
+		}
+	}
+	if len(ignored) > 0 {
+		logger.Warn("ignoring changes that need a restart", slog.Any("settings", ignored))
+	}
+
+	logger.Info("configuration reloaded",
+		slog.String("greeting", applied.Greeting),
+		slog.String("log_level", applied.LogLevel),
+	)
+	return applied
+}
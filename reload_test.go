@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunReloadsOnSIGHUP(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.env")
+	if err := os.WriteFile(file, []byte("GREETING=Moin\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app, _, _ := startRun(t, "CONFIG_FILE="+file)
+
+	if _, body := get(t, "http://"+app+"/"); body != "Moin\n" {
+		t.Fatalf("greeting before the reload: %q, want \"Moin\"", body)
+	}
+
+	if err := os.WriteFile(file, []byte("# reloaded\nGREETING=Servus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, body := get(t, "http://"+app+"/")
+		if body == "Servus\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("greeting after SIGHUP: %q, want \"Servus\"", body)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Setenv("GREETING", "Moin")
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("PORT", "9999")
+
+	current := defaultConfig()
+	var greeting atomic.Pointer[string]
+	greeting.Store(&current.Greeting)
+	var logLevel slog.LevelVar
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	applied := reload(logger, &logLevel, current, &greeting)
+
+	if *greeting.Load() != "Moin" || applied.Greeting != "Moin" {
+		t.Errorf("greeting %q, applied %q, want \"Moin\"", *greeting.Load(), applied.Greeting)
+	}
+	if logLevel.Level() != slog.LevelDebug || applied.LogLevel != "debug" {
+		t.Errorf("log level %s, applied %q, want debug", logLevel.Level(), applied.LogLevel)
+	}
+	if applied.Addr != current.Addr {
+		t.Errorf("address changed to %q without a restart", applied.Addr)
+	}
+	if !strings.Contains(logs.String(), `msg="ignoring changes that need a restart" settings=[Addr]`) {
+		t.Errorf("logs %q, want the ignored address change", logs.String())
+	}
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	logs.Reset()
+	applied = reload(logger, &logLevel, applied, &greeting)
+	if applied.LogLevel != "debug" || logLevel.Level() != slog.LevelDebug {
+		t.Errorf("invalid log level: applied %q, level %s, want debug kept", applied.LogLevel, logLevel.Level())
+	}
+	if !strings.Contains(logs.String(), "log_level=verbose") {
+		t.Errorf("logs %q, want a warning with log_level=verbose", logs.String())
+	}
+
+	t.Setenv("PORT", "nope")
+	if got := reload(logger, &logLevel, applied, &greeting); got.LogLevel != applied.LogLevel || got.Addr != applied.Addr {
+		t.Errorf("invalid configuration applied: %+v", got)
+	}
+}
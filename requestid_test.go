@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	for _, tt := range []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"Trace_1.2:3", true},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"quote\"", false},
+		{"ünïcode", false},
+	} {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	for _, tt := range []struct {
+		name, header string
+		keep         bool
+	}{
+		{"missing", "", false},
+		{"valid", "abc-123", true},
+		{"invalid", "bad id\r\n", false},
+		{"too long", strings.Repeat("a", 129), false},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.header != "" {
+			req.Header.Set(requestIDHeader, tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		got := rec.Header().Get(requestIDHeader)
+		if got != seen {
+			t.Errorf("%s: response ID %q, context ID %q", tt.name, got, seen)
+		}
+		if tt.keep && got != tt.header {
+			t.Errorf("%s: ID %q, want %q", tt.name, got, tt.header)
+		}
+		if !tt.keep && (len(got) != 32 || !validRequestID(got)) {
+			t.Errorf("%s: ID %q, want a new 32 character ID", tt.name, got)
+		}
+	}
+
+	if id := RequestIDFromContext(httptest.NewRequest("GET", "/", nil).Context()); id != "" {
+		t.Errorf("RequestIDFromContext without ID = %q, want empty", id)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Option customizes a server built by NewServer.
+type Option func(*http.Server)
+
+// WithAddr overrides the address the server listens on.
+func WithAddr(addr string) Option {
+	return func(s *http.Server) {
+		s.Addr = addr
+	}
+}
+
+// WithReadTimeout overrides the time allowed to read a whole request.
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *http.Server) {
+		s.ReadTimeout = d
+	}
+}
+
+// WithHandler sets the handler serving all requests.
+func WithHandler(h http.Handler) Option {
+	return func(s *http.Server) {
+		s.Handler = h
+	}
+}
+
+// WithErrorLog sets the logger for errors accepting connections and from
+// handlers, like failed TLS handshakes.
+func WithErrorLog(l *log.Logger) Option {
+	return func(s *http.Server) {
+		s.ErrorLog = l
+	}
+}
+
+// WithTLS makes the server serve HTTPS with cert, accepting TLS 1.2 or newer.
+func WithTLS(cert tls.Certificate) Option {
+	return func(s *http.Server) {
+		s.TLSConfig = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+	}
+}
+
+// WithH2C additionally accepts HTTP/2 without TLS from clients connecting
+// with prior knowledge. There is no encryption, so this is only meant for
+// trusted networks like pod-to-pod traffic inside the cluster.
+func WithH2C() Option {
+	return func(s *http.Server) {
+		var p http.Protocols
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(true)
+		s.Protocols = &p
+	}
+}
+
+// NewServer returns an http.Server configured from cfg. The options are
+// applied last and take precedence over cfg.
+func NewServer(cfg Config, opts ...Option) *http.Server {
+	s := &http.Server{
+		Addr:              cfg.Addr,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	if cfg.DisableKeepAlives {
+		s.SetKeepAlivesEnabled(false)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// listen opens a listener on addr, which is either a TCP address listened
+// on with network, one of tcp, tcp4 or tcp6, or the path of a Unix domain
+// socket prefixed with "unix:". A socket left behind at that path by an
+// earlier run is removed first, and the socket file is removed again when
+// the listener is closed. Errors name the address and explain the common
+// causes of a failed bind. They wrap ErrBind.
+func listen(network, addr string) (net.Listener, error) {
+	var (
+		l   net.Listener
+		err error
+	)
+	path, unix := strings.CutPrefix(addr, "unix:")
+	if unix {
+		l, err = listenUnix(path)
+	} else {
+		l, err = net.Listen(network, addr)
+	}
+	switch {
+	case err == nil:
+		return l, nil
+	case errors.Is(err, syscall.EADDRINUSE):
+		return nil, fmt.Errorf("%w on %s, it is already in use by another process: %w", ErrBind, addr, err)
+	case errors.Is(err, syscall.EACCES) && !unix:
+		return nil, fmt.Errorf("%w on %s, permission denied; ports below 1024 need root or CAP_NET_BIND_SERVICE: %w", ErrBind, addr, err)
+	}
+	return nil, fmt.Errorf("%w on %s: %w", ErrBind, addr, err)
+}
+
+// listenUnix listens on the Unix domain socket at path.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(true)
+	return l, nil
+}
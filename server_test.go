@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for 127.0.0.1 and its key to
+// a temporary directory. It returns both file names and a pool trusting the
+// certificate.
+func writeCert(t *testing.T) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kube-example test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, roots := writeCert(t)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := listenLoopback(t)
+	addr := l.Addr().String()
+	s := NewServer(defaultConfig(), WithTLS(cert), WithHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	})))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, s, l, 5*time.Second, discard) }()
+	defer func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("serve returned %v, want nil", err)
+		}
+	}()
+
+	client := func(maxVersion uint16) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: maxVersion},
+		}}
+	}
+
+	c := client(0)
+	defer c.CloseIdleConnections()
+	resp, err := c.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 || string(body) != "secure" {
+		t.Errorf("HTTPS request: TLS %v, body %q, want TLS 1.2 or newer and \"secure\"", resp.TLS, body)
+	}
+
+	if _, err := client(tls.VersionTLS11).Get("https://" + addr + "/"); err == nil {
+		t.Error("TLS 1.1 handshake succeeded, want it rejected")
+	}
+}
+
+func TestTLSNeedsCertificateAndKey(t *testing.T) {
+	certFile, keyFile, _ := writeCert(t)
+
+	for _, tt := range []struct {
+		name      string
+		cert, key string
+	}{
+		{"certificate only", certFile, ""},
+		{"key only", "", keyFile},
+		{"missing files", "/nonexistent.crt", "/nonexistent.key"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOST", "127.0.0.1")
+			t.Setenv("PORT", "8443")
+			t.Setenv("ADMIN_PORT", "8444")
+			t.Setenv("TLS_CERT_FILE", tt.cert)
+			t.Setenv("TLS_KEY_FILE", tt.key)
+
+			// run fails before it starts serving, without waiting for ctx.
+			errc := make(chan error, 1)
+			go func() { errc <- run(context.Background()) }()
+			select {
+			case err := <-errc:
+				if err == nil {
+					t.Error("run returned nil, want an error")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("run started serving")
+			}
+		})
+	}
+}
+
+// unixClient returns a client sending all requests to the socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestRunOnUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "app.sock")
+	_, _, stop := startRun(t, "LISTEN=unix:"+sock)
+
+	resp, err := unixClient(sock).Get("http://app/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "Ahoi\n" {
+		t.Errorf("GET / over the socket: %d %q, want 200 \"Ahoi\"", resp.StatusCode, body)
+	}
+
+	if err := stop(); err != nil {
+		t.Fatalf("run returned %v", err)
+	}
+	if _, err := os.Lstat(sock); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket still exists after shutdown: %v", err)
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen("tcp", "unix:"+file); !errors.Is(err, ErrBind) {
+		t.Errorf("listening on a regular file: %v, want ErrBind", err)
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("regular file was touched: %v", err)
+	}
+
+	// A socket left behind by an earlier run is replaced.
+	sock := filepath.Join(dir, "stale.sock")
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := listen("tcp", "unix:"+sock)
+	if err != nil {
+		t.Fatalf("listening over a stale socket: %v", err)
+	}
+	l.Close()
+	if _, err := os.Lstat(sock); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket still exists after close: %v", err)
+	}
+}
+
+func TestRunLimitsConnections(t *testing.T) {
+	app, _, _ := startRun(t, "MAX_CONNS=1")
+
+	const req = "GET / HTTP/1.1\r\nHost: app\r\n\r\n"
+	dial := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		conn, err := net.Dial("tcp", app)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		if _, err := io.WriteString(conn, req); err != nil {
+			t.Fatal(err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+
+	// The first connection is kept alive after its response.
+	first, r := dial()
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	second, r := dial()
+	second.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, err := r.Peek(1); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("second connection served while the first is open: %v", err)
+	}
+
+	first.Close()
+	second.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err = http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("second connection after the first closed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("second connection: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestRunH2C(t *testing.T) {
+	app, _, _ := startRun(t, "ENABLE_H2C=true")
+
+	var p http.Protocols
+	p.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: &p}}
+
+	resp, err := client.Get("http://" + app + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol %s, want HTTP/2", resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "Ahoi\n" {
+		t.Errorf("GET /: %d %q, want 200 \"Ahoi\"", resp.StatusCode, body)
+	}
+}
+
+func TestListenIPFamily(t *testing.T) {
+	ipv6 := true
+	if l, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		ipv6 = false
+	} else {
+		l.Close()
+	}
+
+	for _, tt := range []struct {
+		network, addr string
+		ok            bool
+		needsIPv6     bool
+	}{
+		{"tcp4", "127.0.0.1:0", true, false},
+		{"tcp6", "127.0.0.1:0", false, false},
+		{"tcp", "127.0.0.1:0", true, false},
+		{"tcp6", "[::1]:0", true, true},
+		{"tcp4", "[::1]:0", false, true},
+		{"tcp", "[::1]:0", true, true},
+	} {
+		if tt.needsIPv6 && !ipv6 {
+			continue
+		}
+		l, err := listen(tt.network, tt.addr)
+		if err == nil {
+			l.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("listen(%q, %q): %v, want ok %t", tt.network, tt.addr, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrBind) {
+			t.Errorf("listen(%q, %q): %v, want ErrBind", tt.network, tt.addr, err)
+		}
+	}
+	if !ipv6 {
+		t.Skip("no IPv6 loopback, skipped the IPv6 cases")
+	}
+}
+
+func TestListenAddressInUse(t *testing.T) {
+	taken := listenLoopback(t)
+	defer taken.Close()
+	addr := taken.Addr().String()
+
+	_, err := listen("tcp", addr)
+	if !errors.Is(err, ErrBind) {
+		t.Fatalf("listen on a used port: %v, want ErrBind", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, addr) || !strings.Contains(msg, "already in use") {
+		t.Errorf("error %q, want the address and \"already in use\"", msg)
+	}
+}
+
+func TestRunDisableKeepAlives(t *testing.T) {
+	for _, tt := range []struct {
+		disable string
+		close   bool
+	}{
+		{"false", false},
+		{"true", true},
+	} {
+		t.Run("DISABLE_KEEPALIVES="+tt.disable, func(t *testing.T) {
+			app, _, _ := startRun(t, "DISABLE_KEEPALIVES="+tt.disable)
+
+			resp, err := http.Get("http://" + app + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.Close != tt.close {
+				t.Errorf("Connection: close is %t, want %t", resp.Close, tt.close)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatic(t *testing.T) {
+	app, _, _ := startRun(t)
+
+	for _, tt := range []struct {
+		path        string
+		contentType string
+	}{
+		{"/static/style.css", "text/css; charset=utf-8"},
+		{"/static/favicon.svg", "image/svg+xml"},
+	} {
+		resp, err := http.Get("http://" + app + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", tt.path, resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("%s: Content-Type %q, want %q", tt.path, ct, tt.contentType)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "public, max-age=86400" {
+			t.Errorf("%s: Cache-Control %q, want public, max-age=86400", tt.path, cc)
+		}
+	}
+
+	for _, path := range []string{
+		"/static/",
+		"/static/missing.css",
+		"/static/../main.go",
+		"/static/..%2fmain.go",
+		"/static/%2e%2e/main.go",
+	} {
+		status, body := get(t, "http://"+app+path)
+		if status != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, status)
+		}
+		if strings.Contains(body, "style.css") || strings.Contains(body, "package main") {
+			t.Errorf("%s: body %q lists or leaks files", path, body)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// status reports how long the process has been running and a few cheap
+// runtime figures.
+func status(start time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uptime := time.Since(start)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			StartTime     time.Time `json:"startTime"`
+			Uptime        string    `json:"uptime"`
+			UptimeSeconds float64   `json:"uptimeSeconds"`
+			GoVersion     string    `json:"goVersion"`
+			Goroutines    int       `json:"goroutines"`
+		}{start, uptime.Round(time.Millisecond).String(), uptime.Seconds(), runtime.Version(), runtime.NumGoroutine()})
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestStatus(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	rec := httptest.NewRecorder()
+	status(start)(rec, httptest.NewRequest("GET", "/status", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", ct)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"startTime", "uptime", "uptimeSeconds", "goVersion", "goroutines"} {
+		if _, ok := fields[k]; !ok {
+			t.Errorf("missing field %q in %s", k, rec.Body)
+		}
+	}
+
+	var got struct {
+		StartTime     time.Time `json:"startTime"`
+		Uptime        string    `json:"uptime"`
+		UptimeSeconds float64   `json:"uptimeSeconds"`
+		GoVersion     string    `json:"goVersion"`
+		Goroutines    int       `json:"goroutines"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.StartTime.Equal(start) {
+		t.Errorf("startTime %s, want %s", got.StartTime, start)
+	}
+	if got.UptimeSeconds < 60 {
+		t.Errorf("uptimeSeconds %f, want at least 60", got.UptimeSeconds)
+	}
+	if d, err := time.ParseDuration(got.Uptime); err != nil || d < 0 {
+		t.Errorf("uptime %q: %v, want a non-negative duration", got.Uptime, err)
+	}
+	if got.GoVersion != runtime.Version() {
+		t.Errorf("goVersion %q, want %q", got.GoVersion, runtime.Version())
+	}
+	if got.Goroutines < 1 {
+		t.Errorf("goroutines %d, want at least 1", got.Goroutines)
+	}
+}
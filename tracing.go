@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// newTracerProvider returns a tracer provider exporting spans over OTLP/HTTP
+// and installs it, along with W3C trace context propagation, as the global
+// default. The exporter is configured by the standard OTEL_* variables, like
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_SERVICE_NAME.
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exp, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return tp, nil
+}
+
+// tracing starts a span for every request, named after the pattern it
+// matches on mux, or only its method when nothing but the notFound catch-all
+// matches. The span is carried in the request context.
+func tracing(mux *http.ServeMux, tp *sdktrace.TracerProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return otelhttp.NewHandler(next, "http.server",
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				if _, pattern := mux.Handler(r); pattern != "" && pattern != catchAll {
+					return pattern
+				}
+				return r.Method
+			}),
+		)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(t.Context())
+
+	var inHandler trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc(catchAll, notFound)
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		inHandler = trace.SpanContextFromContext(r.Context())
+	})
+	h := tracing(mux, tp)(mux)
+
+	for _, path := range []string{"/version", "/nope"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	for i, want := range []string{"GET /version", "GET"} {
+		if name := spans[i].Name(); name != want {
+			t.Errorf("span %d: name %q, want %q", i, name, want)
+		}
+	}
+	if !inHandler.IsValid() || inHandler.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Errorf("handler saw span %v, want %v", inHandler, spans[0].SpanContext())
+	}
+}